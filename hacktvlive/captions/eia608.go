@@ -0,0 +1,288 @@
+package captions
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EIA-608 miscellaneous control codes for data channel 1 (CC1). Each is sent
+// as the pair {0x14, code}.
+const (
+	ctrlRCL = 0x20 // resume caption loading (pop-on)
+	ctrlRU2 = 0x25 // roll-up captions, 2 rows
+	ctrlEDM = 0x2C // erase displayed memory
+	ctrlCR  = 0x2D // carriage return
+	ctrlENM = 0x2E // erase non-displayed memory
+	ctrlEOC = 0x2F // end of caption (swap memories)
+)
+
+// maxColumns is the width of the caption grid.
+const maxColumns = 32
+
+// pacRows gives the preamble address code bytes for rows 1-15, column 0, white.
+var pacRows = [16][2]byte{
+	{}, {0x11, 0x40}, {0x11, 0x60}, {0x12, 0x40}, {0x12, 0x60}, {0x15, 0x40}, {0x15, 0x60},
+	{0x16, 0x40}, {0x16, 0x60}, {0x17, 0x40}, {0x17, 0x60}, {0x10, 0x40}, {0x13, 0x40},
+	{0x13, 0x60}, {0x14, 0x40}, {0x14, 0x60},
+}
+
+// specialChars maps the runes the EIA-608 basic character set places on top of
+// ASCII punctuation.
+var specialChars = map[rune]byte{
+	'á': 0x2A, 'é': 0x5C, 'í': 0x5E, 'ó': 0x5F, 'ú': 0x60,
+	'ç': 0x7B, '÷': 0x7C, 'Ñ': 0x7D, 'ñ': 0x7E, '█': 0x7F,
+}
+
+// pairsPerSecond is the line 21 data rate: one byte pair per field 1.
+const pairsPerSecond = 30000.0 / 1001.0
+
+// Encoder turns caption text into the EIA-608 byte pairs carried on line 21.
+// Field 1 carries CC1 captions, either pop-on captions from a subtitle file
+// or roll-up captions typed at runtime. Field 2 carries an XDS packet giving
+// the station's network name.
+type Encoder struct {
+	mu      sync.Mutex
+	start   time.Time
+	pending [][2]byte
+	cues    []Cue
+	nextCue int
+	clears  []time.Duration // end times of the captions sent, in order
+	// midControl is set between the two copies of a doubled control code.
+	midControl bool
+	xds        [][2]byte
+	xdsPos     int
+}
+
+// NewEncoder creates a caption encoder that identifies the station as callsign
+// in the XDS data on field 2.
+func NewEncoder(callsign string) *Encoder {
+	e := &Encoder{}
+	e.xds = xdsPacket(0x05, 0x01, callsign)
+	// Repeat the packet roughly once a second, with nulls in between.
+	for len(e.xds) < 30 {
+		e.xds = append(e.xds, [2]byte{0, 0})
+	}
+	return e
+}
+
+// LoadCues schedules pop-on captions. Cue times are measured from the first
+// frame that carries captions.
+func (e *Encoder) LoadCues(cues []Cue) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cues = cues
+	e.nextCue = 0
+	e.clears = nil
+}
+
+// ReadLive sends each line read from r as a roll-up caption until r is closed.
+func (e *Encoder) ReadLive(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		e.SendLive(scanner.Text())
+	}
+}
+
+// SendLive queues text as roll-up captions on the bottom two rows.
+func (e *Encoder) SendLive(text string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, row := range wrap(text) {
+		e.control(ctrlRU2)
+		e.control(ctrlCR)
+		e.pac(15)
+		e.text(row)
+	}
+}
+
+// NextPair returns the byte pair, with odd parity applied, to send on line 21
+// of the given field (1 or 2). It is called once per field.
+func (e *Encoder) NextPair(field int) (byte, byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var pair [2]byte
+	if field == 2 {
+		pair = e.xds[e.xdsPos]
+		e.xdsPos = (e.xdsPos + 1) % len(e.xds)
+		return withParity(pair[0]), withParity(pair[1])
+	}
+
+	if e.start.IsZero() {
+		e.start = time.Now()
+	}
+	pair = e.nextCaptionPair(time.Since(e.start))
+	return withParity(pair[0]), withParity(pair[1])
+}
+
+// nextCaptionPair schedules captions up to now, measured from the first
+// caption field, and takes the next CC1 pair to send, without parity.
+func (e *Encoder) nextCaptionPair(now time.Duration) [2]byte {
+	e.schedule(now)
+	if len(e.pending) == 0 {
+		return [2]byte{}
+	}
+	pair := e.pending[0]
+	e.pending = e.pending[1:]
+	// Control codes, preamble address codes included, are always queued
+	// in twos; note when the first of a two has gone out.
+	e.midControl = isControl(pair) && !e.midControl
+	return pair
+}
+
+// isControl reports whether a pair is a control code or preamble address
+// code rather than printable characters.
+func isControl(pair [2]byte) bool {
+	return pair[0]&0x70 == 0x10
+}
+
+// schedule queues the next pop-on caption early enough that its EOC lands on
+// the cue start, and clears the screen as each caption expires. A caption
+// still showing when the next one starts is replaced by it rather than
+// cleared. The clear goes ahead of anything still queued, so it is not held
+// up behind the next caption loading, which goes to non-displayed memory,
+// but never between the two copies of a control code: a second EOC sent
+// after the clear would be taken as a new one and swap the memories back.
+func (e *Encoder) schedule(now time.Duration) {
+	if e.nextCue < len(e.cues) {
+		cue := e.cues[e.nextCue]
+		rows := wrap(cue.Text)
+		if len(rows) > 4 {
+			rows = rows[len(rows)-4:]
+		}
+
+		before := len(e.pending)
+		e.control(ctrlRCL)
+		e.control(ctrlENM)
+		for i, row := range rows {
+			e.pac(16 - len(rows) + i)
+			e.text(row)
+		}
+		e.control(ctrlEOC)
+		lead := time.Duration(float64(len(e.pending)) / pairsPerSecond * float64(time.Second))
+
+		if now+lead < cue.Start {
+			e.pending = e.pending[:before]
+		} else {
+			e.nextCue++
+			for len(e.clears) > 0 && e.clears[len(e.clears)-1] >= cue.Start {
+				e.clears = e.clears[:len(e.clears)-1]
+			}
+			e.clears = append(e.clears, cue.End)
+		}
+	}
+
+	for len(e.clears) > 0 && now >= e.clears[0] {
+		e.clears = e.clears[1:]
+		at := 0
+		if e.midControl {
+			at = 1
+		}
+		edm := [2]byte{0x14, ctrlEDM}
+		e.pending = slices.Insert(e.pending, at, edm, edm)
+	}
+}
+
+// control queues a control code. Control codes are sent twice in a row so a
+// single corrupted field cannot drop them.
+func (e *Encoder) control(code byte) {
+	e.pending = append(e.pending, [2]byte{0x14, code}, [2]byte{0x14, code})
+}
+
+// pac queues a preamble address code moving the cursor to column 0 of row.
+func (e *Encoder) pac(row int) {
+	e.pending = append(e.pending, pacRows[row], pacRows[row])
+}
+
+// text queues printable characters, two per pair.
+func (e *Encoder) text(s string) {
+	var chars []byte
+	for _, r := range s {
+		chars = append(chars, toEIA608(r))
+	}
+	for i := 0; i < len(chars); i += 2 {
+		pair := [2]byte{chars[i], 0}
+		if i+1 < len(chars) {
+			pair[1] = chars[i+1]
+		}
+		e.pending = append(e.pending, pair)
+	}
+}
+
+// xdsPacket builds an XDS packet: start/type code, informational characters,
+// then the end code and a checksum that makes the packet sum to zero mod 128.
+func xdsPacket(class, typ byte, info string) [][2]byte {
+	packet := [][2]byte{{class, typ}}
+	sum := int(class) + int(typ)
+	chars := []byte(info)
+	for i := 0; i < len(chars); i += 2 {
+		pair := [2]byte{chars[i] & 0x7F, 0}
+		if i+1 < len(chars) {
+			pair[1] = chars[i+1] & 0x7F
+		}
+		sum += int(pair[0]) + int(pair[1])
+		packet = append(packet, pair)
+	}
+	sum += 0x0F
+	return append(packet, [2]byte{0x0F, byte((128 - sum%128) % 128)})
+}
+
+// toEIA608 maps a rune onto the basic character set. ASCII punctuation that
+// the set reuses for accented letters is replaced, as is anything unsupported.
+func toEIA608(r rune) byte {
+	if b, ok := specialChars[r]; ok {
+		return b
+	}
+	switch r {
+	case '*', '\\', '^', '_', '`', '{', '|', '}', '~':
+		return '-'
+	}
+	if r < 0x20 || r > 0x7E {
+		return '?'
+	}
+	return byte(r)
+}
+
+// wrap splits caption text into rows no wider than the caption grid.
+func wrap(text string) []string {
+	var rows []string
+	for _, line := range strings.Split(text, "\n") {
+		var row string
+		for _, word := range strings.Fields(line) {
+			switch {
+			case row == "":
+				row = word
+			case len([]rune(row))+1+len([]rune(word)) <= maxColumns:
+				row += " " + word
+			default:
+				rows = append(rows, row)
+				row = word
+			}
+			for len([]rune(row)) > maxColumns {
+				rows = append(rows, string([]rune(row)[:maxColumns]))
+				row = string([]rune(row)[maxColumns:])
+			}
+		}
+		if row != "" {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// withParity sets bit 7 so the byte has odd parity.
+func withParity(b byte) byte {
+	b &= 0x7F
+	ones := 0
+	for v := b; v != 0; v >>= 1 {
+		ones += int(v & 1)
+	}
+	if ones%2 == 0 {
+		b |= 0x80
+	}
+	return b
+}
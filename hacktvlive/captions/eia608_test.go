@@ -0,0 +1,145 @@
+package captions
+
+import (
+	"math"
+	"math/bits"
+	"testing"
+	"time"
+)
+
+func TestWithParity(t *testing.T) {
+	for b := 0; b < 0x80; b++ {
+		got := withParity(byte(b))
+		if got&0x7F != byte(b) {
+			t.Errorf("withParity(%#02x) = %#02x changes the data bits", b, got)
+		}
+		if bits.OnesCount8(got)%2 != 1 {
+			t.Errorf("withParity(%#02x) = %#02x has even parity", b, got)
+		}
+	}
+	if got := withParity(0x94); got != 0x94 {
+		t.Errorf("withParity(0x94) = %#02x, want the parity bit recomputed to 0x94", got)
+	}
+}
+
+func TestXDSPacket(t *testing.T) {
+	for _, info := range []string{"NOCALL", "G4ABC"} {
+		packet := xdsPacket(0x05, 0x01, info)
+		if packet[0] != [2]byte{0x05, 0x01} {
+			t.Errorf("%q: starts with %#v, want the class and type", info, packet[0])
+		}
+		end := packet[len(packet)-1]
+		if end[0] != 0x0F {
+			t.Errorf("%q: ends with %#v, want the end code", info, end)
+		}
+		var chars []byte
+		sum := 0
+		for i, pair := range packet {
+			sum += int(pair[0]) + int(pair[1])
+			if i > 0 && i < len(packet)-1 {
+				chars = append(chars, pair[0])
+				if pair[1] != 0 {
+					chars = append(chars, pair[1])
+				}
+			}
+		}
+		if string(chars) != info {
+			t.Errorf("%q: carries %q", info, chars)
+		}
+		if sum%128 != 0 {
+			t.Errorf("%q: sums to %d mod 128, want 0", info, sum%128)
+		}
+	}
+}
+
+// sent runs an encoder a caption field at a time for the given duration and
+// returns the CC1 pairs with the time each went out.
+func sent(e *Encoder, d time.Duration) (pairs [][2]byte, at []time.Duration) {
+	frames := int(d.Seconds() * pairsPerSecond)
+	for f := 0; f < frames; f++ {
+		now := time.Duration(float64(f) / pairsPerSecond * float64(time.Second))
+		pairs = append(pairs, e.nextCaptionPair(now))
+		at = append(at, now)
+	}
+	return pairs, at
+}
+
+// checkDoubled fails the test unless every control code in pairs is sent
+// twice in a row.
+func checkDoubled(t *testing.T, name string, pairs [][2]byte) {
+	t.Helper()
+	for i := 0; i < len(pairs); i++ {
+		if !isControl(pairs[i]) {
+			continue
+		}
+		if i+1 == len(pairs) || pairs[i+1] != pairs[i] {
+			t.Errorf("%s: control code %#v at pair %d is not doubled", name, pairs[i], i)
+			return
+		}
+		i++
+	}
+}
+
+func TestControlCodesDoubled(t *testing.T) {
+	e := NewEncoder("NOCALL")
+	e.SendLive("HELLO WORLD")
+	pairs, _ := sent(e, time.Second)
+	checkDoubled(t, "roll-up", pairs)
+
+	// A cue ending just before the next one starts has its clear come due
+	// while the next one's EOC is going out; it must not split the EOC.
+	for gap := 10 * time.Millisecond; gap <= 100*time.Millisecond; gap += 5 * time.Millisecond {
+		e := NewEncoder("NOCALL")
+		e.LoadCues([]Cue{
+			{Start: 0, End: 2*time.Second - gap, Text: "FIRST"},
+			{Start: 2 * time.Second, End: 3 * time.Second, Text: "SECOND"},
+		})
+		pairs, _ := sent(e, 4*time.Second)
+		checkDoubled(t, "pop-on gap "+gap.String(), pairs)
+	}
+}
+
+func TestScheduleClears(t *testing.T) {
+	e := NewEncoder("NOCALL")
+	e.LoadCues([]Cue{
+		{Start: 0, End: 2 * time.Second, Text: "A"},
+		// B is queued before A's clear is due, and replaced by C while it
+		// is still showing.
+		{Start: 2100 * time.Millisecond, End: 4 * time.Second, Text: "B"},
+		{Start: 3 * time.Second, End: 5 * time.Second, Text: "C"},
+	})
+	pairs, at := sent(e, 6*time.Second)
+
+	var eoc, edm []time.Duration
+	for i := 0; i < len(pairs); i++ {
+		if !isControl(pairs[i]) {
+			continue
+		}
+		switch pairs[i] {
+		case [2]byte{0x14, ctrlEOC}:
+			eoc = append(eoc, at[i])
+		case [2]byte{0x14, ctrlEDM}:
+			edm = append(edm, at[i])
+		}
+		i++
+	}
+
+	near := func(got []time.Duration, want ...float64) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			// Within two fields of the wanted time.
+			if math.Abs(got[i].Seconds()-want[i]) > 2/pairsPerSecond {
+				return false
+			}
+		}
+		return true
+	}
+	if !near(eoc, 0.2, 2.1, 3) {
+		t.Errorf("captions shown at %v, want about 0.2s (after loading), 2.1s and 3s", eoc)
+	}
+	if !near(edm, 2, 5) {
+		t.Errorf("screen cleared at %v, want 2s and 5s only", edm)
+	}
+}
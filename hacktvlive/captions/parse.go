@@ -0,0 +1,100 @@
+package captions
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cue is a single timed caption.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+var (
+	timingLine = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{3})`)
+	markupTag  = regexp.MustCompile(`<[^>]*>|\{[^}]*\}`)
+)
+
+// ParseFile reads an SRT or WebVTT subtitle file. Both formats are handled by
+// the same parser: cue numbers, identifiers, WebVTT headers, NOTE blocks and
+// cue settings are skipped, and inline markup is stripped from the text.
+func ParseFile(path string) ([]Cue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open captions: %w", err)
+	}
+	defer f.Close()
+
+	var cues []Cue
+	var cur *Cue
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		if strings.TrimSpace(line) == "" {
+			cur = nil
+			continue
+		}
+		if m := timingLine.FindStringSubmatch(line); m != nil {
+			start, err := parseTimestamp(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			end, err := parseTimestamp(m[2])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			if end < start {
+				return nil, fmt.Errorf("%s:%d: cue ends before it starts", path, lineNo)
+			}
+			cues = append(cues, Cue{Start: start, End: end})
+			cur = &cues[len(cues)-1]
+			continue
+		}
+		if cur == nil {
+			// Cue number, cue identifier, WEBVTT header or NOTE/STYLE block.
+			continue
+		}
+
+		text := strings.TrimSpace(markupTag.ReplaceAllString(line, ""))
+		if cur.Text != "" {
+			cur.Text += "\n"
+		}
+		cur.Text += text
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read captions: %w", err)
+	}
+	return cues, nil
+}
+
+// parseTimestamp accepts "HH:MM:SS,mmm" (SRT) and "[HH:]MM:SS.mmm" (WebVTT).
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	parts := strings.Split(s, ":")
+	var whole int
+	for _, part := range parts[:len(parts)-1] {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("bad timestamp %q", s)
+		}
+		whole = whole*60 + n
+	}
+	secs, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad timestamp %q", s)
+	}
+	return time.Duration(whole)*time.Minute + time.Duration(secs*float64(time.Second)), nil
+}
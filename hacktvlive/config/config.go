@@ -1,32 +1,133 @@
 package config
 
-import "flag"
-
-// FixedSampleRate is the constant sample rate for the HackRF, set to 8 Msps.
-const FixedSampleRate = 8_000_000.0
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 // Config holds all application configuration values.
 type Config struct {
-	Frequency float64
-	Bandwidth float64
-	Gain      int
-	Device    string
-	Callsign  string
-	Test      bool
-	PAL       bool
+	Frequency        float64
+	SampleRate       float64
+	Bandwidth        float64
+	Offset           float64
+	Modulation       string
+	Deviation        float64
+	PreEmphasis      string
+	AudioSubcarriers string
+	AudioTone        float64
+	Gain             int
+	Device           string
+	Callsign         string
+	Test             bool
+	Standard         string
+	StdFile          string
+	FieldOrder       string
+	Progressive      bool
+	FieldRate        bool
+	Width            int
+	ChromaLPF        bool
+	LumaLPF          bool
+	LumaNotch        bool
+	Interp           string
+	Workers          int
+	Captions         string
+	CCLive           bool
+	Teletext         string
+	TTXLines         string
+	Subtitles        string
+	VITS             string
+	Widescreen       bool
+	VITC             bool
+	VITCLines        string
+	BurnIn           bool
 }
 
 // New creates and returns a new Config struct populated from command-line flags.
 func New() *Config {
 	cfg := &Config{}
 	flag.Float64Var(&cfg.Frequency, "freq", 1280, "Transmit frequency in MHz")
-	flag.Float64Var(&cfg.Bandwidth, "bw", 1.5, "Channel bandwidth in MHz for filtering")
+	flag.Float64Var(&cfg.SampleRate, "sample-rate", 8, "HackRF sample rate in Msps, 2-20 (above 13.5, or below twice the colour subcarrier, the video is rendered at 13.5 Msps and resampled to it)")
+	flag.Float64Var(&cfg.Bandwidth, "bw", 0, "RF channel width in MHz, from the bottom of the vestigial sideband to the top of the upper sideband (default: the standard's vestige plus video bandwidth)")
+	flag.Float64Var(&cfg.Offset, "offset", 0, "IF offset in MHz: the HackRF is tuned this far below -freq and the signal mixed up to meet it, moving the LO leakage off the carrier")
+	flag.StringVar(&cfg.Modulation, "mod", "am", "Modulation: am (vestigial sideband) or fm (FM-ATV)")
+	flag.Float64Var(&cfg.Deviation, "deviation", 2, "FM deviation in MHz from sync tip to peak white")
+	flag.StringVar(&cfg.PreEmphasis, "preemphasis", "none", "FM video pre-emphasis: none, 405 (CCIR 405, 625-line) or 567 (CCIR 567, 525-line)")
+	flag.StringVar(&cfg.AudioSubcarriers, "audio-subcarriers", "", "FM sound subcarriers in MHz, e.g. 6.0,6.5 (need a sample rate above twice the highest)")
+	flag.Float64Var(&cfg.AudioTone, "audio-tone", 0, "Test tone in Hz on the FM sound subcarriers (0 sends the bare carriers)")
 	flag.IntVar(&cfg.Gain, "gain", 30, "TX VGA gain (0-47)")
 	flag.StringVar(&cfg.Device, "device", "", "Video device name or index (OS-dependent)")
 	flag.StringVar(&cfg.Callsign, "callsign", "NOCALL", "Callsign to overlay on the video")
 	flag.BoolVar(&cfg.Test, "test", false, "Show SMPTE colorbar test screen instead of webcam")
-	flag.BoolVar(&cfg.PAL, "pal", false, "Use PAL standard instead of NTSC")
+	flag.StringVar(&cfg.Standard, "standard", "ntsc", "Video standard: ntsc, ntsc-j, ntsc-443, pal, pal-m, pal-n, pal-60 or secam")
+	flag.StringVar(&cfg.StdFile, "standard-file", "", "Load a user-defined video standard from a JSON file (overrides -standard)")
+	flag.StringVar(&cfg.FieldOrder, "field-order", "", "Which field carries the top picture line: tff or bff (default from the standard)")
+	flag.BoolVar(&cfg.Progressive, "progressive", false, "Send non-interlaced 240p (525-line) or 288p (625-line) video")
+	flag.IntVar(&cfg.Width, "width", 0, "Width in pixels of the captured picture (default from the standard, 720)")
+	flag.StringVar(&cfg.Interp, "interp", "linear", "Horizontal resampling of the picture: nearest, linear, cubic or lanczos")
+	flag.IntVar(&cfg.Workers, "workers", 0, "Goroutines rendering each field's lines in parallel (default one per CPU)")
+	flag.BoolVar(&cfg.ChromaLPF, "chroma-filter", true, "Band-limit chroma: I 1.3 MHz and Q 0.4 MHz on NTSC, U/V 1.3 MHz on PAL")
+	flag.BoolVar(&cfg.LumaLPF, "luma-filter", false, "Low-pass luma at the standard's video bandwidth")
+	flag.BoolVar(&cfg.LumaNotch, "luma-notch", false, "Notch the colour subcarrier out of luma to reduce cross-colour")
+	flag.BoolVar(&cfg.FieldRate, "field-rate", false, "Capture at the field rate (59.94/50 fps) and draw each field from its own picture")
+	flag.StringVar(&cfg.Captions, "captions", "", "SRT or WebVTT file to send as EIA-608 closed captions (525-line only)")
+	flag.BoolVar(&cfg.CCLive, "cc-live", false, "Send lines typed on stdin as live roll-up closed captions (525-line only)")
+	flag.StringVar(&cfg.Teletext, "teletext", "", "Directory of TTI pages to broadcast as teletext (PAL only)")
+	flag.StringVar(&cfg.TTXLines, "teletext-lines", "", "VBI lines for teletext, e.g. 7-22,320-335 (default all of those)")
+	flag.StringVar(&cfg.Subtitles, "subtitles", "", "SRT or WebVTT file to send as teletext subtitles on page 888 (PAL only)")
+	flag.StringVar(&cfg.VITS, "vits", "", "VBI test lines: ntc7, its, or line=signal pairs such as 17=its17,18=multiburst")
+	flag.BoolVar(&cfg.Widescreen, "widescreen", false, "Send 16:9 anamorphic video and signal it with WSS (625-line) or CGMS-A (525-line)")
+	flag.BoolVar(&cfg.VITC, "vitc", false, "Send the frame counter as SMPTE 12M vertical interval timecode")
+	flag.StringVar(&cfg.VITCLines, "vitc-lines", "", "Two field 1 lines for VITC, e.g. 14,16 (default 14,16 for 525-line, 19,21 for 625-line)")
+	flag.BoolVar(&cfg.BurnIn, "burn-in", false, "Burn the frame timecode into the top left corner of the picture")
 	flag.Parse()
 
 	return cfg
-}
\ No newline at end of file
+}
+
+// ParseFrequencies parses a comma-separated list of frequencies in MHz, such
+// as "6.0,6.5", into Hz.
+func ParseFrequencies(spec string) ([]float64, error) {
+	var freqs []float64
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mhz, err := strconv.ParseFloat(part, 64)
+		if err != nil || mhz <= 0 {
+			return nil, fmt.Errorf("bad frequency %q", part)
+		}
+		freqs = append(freqs, mhz*1e6)
+	}
+	return freqs, nil
+}
+
+// ParseLines parses a comma-separated list of line numbers and ranges, such
+// as "7-22,320-335".
+func ParseLines(spec string) ([]int, error) {
+	var lines []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("bad line number %q", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("bad line range %q", part)
+			}
+		}
+		for line := first; line <= last; line++ {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,106 @@
+// Package dsp holds the filter design and filtering helpers shared by the
+// video encoders and the transmitter.
+package dsp
+
+import "math"
+
+// NewLowPassFilterTaps creates the coefficients (taps) for a FIR low-pass filter.
+// A Blackman window is used for good performance.
+func NewLowPassFilterTaps(numTaps int, bandwidth, sampleRate float64) []float64 {
+	taps := make([]float64, numTaps)
+	cutoffFreq := bandwidth / 2.0
+	normalizedCutoff := cutoffFreq / sampleRate
+
+	M := float64(numTaps - 1)
+	var sum float64
+	for i := 0; i < numTaps; i++ {
+		n := float64(i)
+		window := 0.42 - 0.5*math.Cos(2*math.Pi*n/M) + 0.08*math.Cos(4*math.Pi*n/M)
+
+		var sinc float64
+		if i == int(M/2) {
+			sinc = 2 * math.Pi * normalizedCutoff
+		} else {
+			sinc = math.Sin(2*math.Pi*normalizedCutoff*(n-M/2)) / (n - M/2)
+		}
+
+		taps[i] = sinc * window
+		sum += taps[i]
+	}
+
+	// Normalize the taps to have a gain of 1 at DC (0 Hz)
+	for i := range taps {
+		taps[i] /= sum
+	}
+	return taps
+}
+
+// NewComplexBandPassTaps creates a complex FIR filter passing from low to
+// high Hz, which may lie either side of 0 Hz: a low-pass half the band wide,
+// shifted up to the middle of the band. The real and imaginary parts of the
+// taps are returned separately; the real part is symmetric and the imaginary
+// part antisymmetric about the centre tap.
+func NewComplexBandPassTaps(numTaps int, low, high, sampleRate float64) (re, im []float64) {
+	taps := NewLowPassFilterTaps(numTaps, high-low, sampleRate)
+	w := 2 * math.Pi * (low + high) / 2 / sampleRate
+	re = make([]float64, numTaps)
+	im = make([]float64, numTaps)
+	for i, tap := range taps {
+		n := float64(i - (numTaps-1)/2)
+		re[i] = tap * math.Cos(w*n)
+		im[i] = tap * math.Sin(w*n)
+	}
+	return re, im
+}
+
+// Filter convolves x with a symmetric (linear-phase) FIR filter, such as
+// those from NewLowPassFilterTaps, and returns the result, aligned with x.
+// Samples beyond either end of x are taken to repeat the end sample, so a
+// filtered segment does not ring against the signal around it. The result is
+// written over out, which must not overlap x, and out is only reallocated if
+// it is shorter than x, so a caller filtering line after line can pass back
+// the slice it was given last time.
+func Filter(out, x, taps []float64) []float64 {
+	if cap(out) < len(x) {
+		out = make([]float64, len(x))
+	}
+	out = out[:len(x)]
+	if len(x) == 0 {
+		return out
+	}
+	half := len(taps) / 2
+	last := len(x) - 1
+	for n := range out {
+		var acc float64
+		if n >= half && n+len(taps)-half <= len(x) {
+			// Away from the ends, fold the window about its centre so each
+			// tap of the symmetric filter is only multiplied once.
+			window := x[n-half : n-half+len(taps)]
+			end := len(window) - 1
+			for k := 0; k < half; k++ {
+				acc += taps[k] * (window[k] + window[end-k])
+			}
+			if len(taps)%2 == 1 {
+				acc += taps[half] * window[half]
+			}
+		} else {
+			for k, tap := range taps {
+				i := min(max(n+k-half, 0), last)
+				acc += tap * x[i]
+			}
+		}
+		out[n] = acc
+	}
+	return out
+}
+
+// Convolve returns the filter made by applying a and b in turn.
+func Convolve(a, b []float64) []float64 {
+	out := make([]float64, len(a)+len(b)-1)
+	for i, x := range a {
+		for j, y := range b {
+			out[i+j] += x * y
+		}
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package dsp
+
+import "math"
+
+// sineBits sets the size of the sine table. 4096 entries put the worst phase
+// error at 0.04 degrees, with spurs some 60 dB down: well below what the
+// HackRF's 8-bit DAC can resolve.
+const (
+	sineBits = 12
+	sineSize = 1 << sineBits
+)
+
+// sineTable holds one cycle of sine, plus a quarter cycle more so cosine can
+// be read from the same table.
+var sineTable = func() []float64 {
+	t := make([]float64, sineSize+sineSize/4)
+	for i := range t {
+		t[i] = math.Sin(2 * math.Pi * float64(i) / sineSize)
+	}
+	return t
+}()
+
+// NCO is a numerically-controlled oscillator: a 32-bit phase accumulator, a
+// whole cycle being 2^32, read through a sine table, so each sample costs a
+// table lookup in place of math.Sin and math.Cos. The accumulator wraps
+// exactly, so the phase does not drift however long it runs.
+type NCO struct {
+	phase, step uint32
+}
+
+// NewNCO starts an oscillator at a phase in radians, stepping on by
+// freq/sampleRate of a cycle each sample. The frequency may be negative.
+func NewNCO(phase, freq, sampleRate float64) NCO {
+	o := NCO{phase: phaseWord(phase / (2 * math.Pi))}
+	o.Tune(freq, sampleRate)
+	return o
+}
+
+// Tune sets the frequency from the next sample on, for FM.
+func (o *NCO) Tune(freq, sampleRate float64) {
+	o.step = phaseWord(freq / sampleRate)
+}
+
+// Next returns the sine and cosine of the current phase and steps on a
+// sample.
+func (o *NCO) Next() (sin, cos float64) {
+	i := (o.phase + 1<<(31-sineBits)) >> (32 - sineBits) & (sineSize - 1)
+	o.phase += o.step
+	return sineTable[i], sineTable[i+sineSize/4]
+}
+
+// phaseWord converts a phase in cycles into accumulator units.
+func phaseWord(cycles float64) uint32 {
+	return uint32(uint64(math.Round((cycles - math.Floor(cycles)) * (1 << 32))))
+}
@@ -0,0 +1,39 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+// NTSC subcarrier at the HackRF's 8 Msps, and an active line's worth of
+// samples.
+const (
+	benchFsc  = 315e6 / 88
+	benchRate = 8e6
+)
+
+var subcarrierLine = make([]float64, 421)
+
+// BenchmarkSubcarrier puts I and Q onto the subcarrier across an active
+// line, with math.Sin and math.Cos every sample and with the NCO's sine
+// table.
+func BenchmarkSubcarrier(b *testing.B) {
+	b.Run("math.Sin", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			phase, step := 0.3, 2*math.Pi*benchFsc/benchRate
+			for k := range subcarrierLine {
+				subcarrierLine[k] = 0.2*math.Cos(phase) + 0.1*math.Sin(phase)
+				phase += step
+			}
+		}
+	})
+	b.Run("NCO", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			nco := NewNCO(0.3, benchFsc, benchRate)
+			for k := range subcarrierLine {
+				sin, cos := nco.Next()
+				subcarrierLine[k] = 0.2*cos + 0.1*sin
+			}
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package dsp
+
+import "math"
+
+// resamplerPhases is how many sub-sample positions the resampler's filter
+// bank is designed for. Each output is taken from the nearest, which puts it
+// within 1/1024 of an input sample of where it belongs: 0.07 ns at 13.5 MHz,
+// or a quarter of a degree of a 4.43 MHz subcarrier.
+const resamplerPhases = 512
+
+// Resampler converts a real signal from one sample rate to another, of any
+// ratio, with a polyphase filter bank: a low-pass filter designed at the
+// input rate times resamplerPhases and split into that many short filters,
+// one for each position an output can fall between two input samples. The
+// filter state runs on from one call to the next, so the signal can be fed
+// in blocks of any length without seams.
+type Resampler struct {
+	step  float64   // input samples per output sample
+	taps  int       // taps per phase
+	bank  []float64 // resamplerPhases rows of taps, the filter for each position
+	pos   float64   // position of the next output in buf, in input samples
+	buf   []float64 // input still inside the filter
+	first int       // offset from an output's position to its first tap
+}
+
+// NewResampler designs a resampler from inRate to outRate that passes the
+// signal flat up to passband Hz. The filter is cut at half the lower of the
+// two rates and falls to -70 dB by the lower rate less passband, so nothing
+// folds back below passband: the closer the passband runs to the edge, the
+// longer the filter. The passband is held to 45% of the lower rate.
+func NewResampler(inRate, outRate, passband float64) *Resampler {
+	low := math.Min(inRate, outRate)
+	passband = math.Min(passband, 0.45*low)
+
+	// A Blackman window takes about 5.5 times the sample rate divided by the
+	// filter length to fall from 0 to -70 dB.
+	taps := int(math.Ceil(5.5 * inRate / (low - 2*passband)))
+	taps += taps % 2
+	proto := NewLowPassFilterTaps(resamplerPhases*taps+1, low, inRate*resamplerPhases)
+
+	r := &Resampler{
+		step:  inRate / outRate,
+		taps:  taps,
+		bank:  make([]float64, resamplerPhases*taps),
+		first: 1 - taps/2,
+	}
+	// Row p, tap j weighs the input sample first+j whole samples after an
+	// output that falls p/resamplerPhases of a sample beyond a sample. Each
+	// row is scaled to unity gain at DC on its own, so the sub-sample
+	// position leaves no ripple on flat areas.
+	for p := 0; p < resamplerPhases; p++ {
+		row := r.bank[p*taps : (p+1)*taps]
+		var sum float64
+		for j := range row {
+			row[j] = proto[resamplerPhases*(taps/2-r.first-j)+p]
+			sum += row[j]
+		}
+		for j := range row {
+			row[j] /= sum
+		}
+	}
+	// Start with the first output's taps at the start of the input. The
+	// output lags the input by half the filter.
+	r.pos = float64(-r.first)
+	return r
+}
+
+// Process resamples the next block of input and appends the outputs it
+// completes to out.
+func (r *Resampler) Process(out, in []float64) []float64 {
+	r.buf = append(r.buf, in...)
+	for {
+		whole := math.Floor(r.pos)
+		i := int(whole)
+		p := int(math.Round((r.pos - whole) * resamplerPhases))
+		if p == resamplerPhases {
+			i, p = i+1, 0
+		}
+		start := i + r.first
+		if start+r.taps > len(r.buf) {
+			break
+		}
+		row := r.bank[p*r.taps : (p+1)*r.taps]
+		x := r.buf[start : start+r.taps]
+		var acc float64
+		for j, tap := range row {
+			acc += tap * x[j]
+		}
+		out = append(out, acc)
+		r.pos += r.step
+	}
+	// Keep only the input the next output still needs.
+	if drop := min(int(math.Floor(r.pos))+r.first, len(r.buf)); drop > 0 {
+		n := copy(r.buf, r.buf[drop:])
+		r.buf = r.buf[:n]
+		r.pos -= float64(drop)
+	}
+	return out
+}
@@ -28,3 +28,5 @@ require (
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.3.8 // indirect
 )
+
+replace github.com/samuel/go-hackrf => /tmp/rv/stub
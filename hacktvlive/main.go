@@ -5,12 +5,13 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/samuel/go-hackrf/hackrf"
+	"hacktvlive/captions"
 	"hacktvlive/config"
 	"hacktvlive/sdr"
 	"hacktvlive/source"
+	"hacktvlive/teletext"
 	"hacktvlive/video"
 )
 
@@ -29,31 +30,172 @@ func main() {
 	}
 	defer dev.Close()
 
-	// 2. Select the video standard (NTSC or PAL) using the fixed sample rate
-	var videoStandard video.Standard
-	var frameTick time.Duration
-	if cfg.PAL {
-		videoStandard = video.NewPAL(config.FixedSampleRate)
-		frameTick = time.Second / 25
+	// 2. Select the video standard (preset or definition file). It is rendered at
+	// -sample-rate where that carries it, and otherwise at video.RenderRate and
+	// resampled by the transmitter.
+	params, ok := video.Presets[cfg.Standard]
+	if cfg.StdFile != "" {
+		params, err = video.LoadParams(cfg.StdFile)
+		if err != nil {
+			log.Fatalf("Invalid video standard: %v", err)
+		}
+	} else if !ok {
+		log.Fatalf("Unknown video standard %q", cfg.Standard)
+	}
+	if cfg.FieldOrder != "" {
+		order, ok := video.FieldOrders[cfg.FieldOrder]
+		if !ok {
+			log.Fatalf("Unknown field order %q (want tff or bff)", cfg.FieldOrder)
+		}
+		if params, err = video.WithFieldOrder(params, order); err != nil {
+			log.Fatalf("Invalid video standard with -field-order %s: %v", cfg.FieldOrder, err)
+		}
+	}
+	if cfg.Progressive {
+		if params, err = video.Progressive(params); err != nil {
+			log.Fatalf("Cannot use -progressive with %s: %v", params.Name, err)
+		}
+	}
+	if cfg.Width > 0 {
+		params.RasterWidth = cfg.Width
+	}
+	videoStandard := video.New(params, video.RenderRateFor(params, cfg.SampleRate*1e6))
+	if params.Progressive {
+		log.Printf("Video standard: %s", params.Name)
 	} else {
-		videoStandard = video.NewNTSC(config.FixedSampleRate)
-		frameTick = time.Second * 1001 / 30000
+		log.Printf("Video standard: %s (%s)", params.Name, params.FieldOrder)
+	}
+
+	// Vertical interval test signals
+	var testLines map[int]video.TestSignal
+	if cfg.VITS != "" {
+		testLines, err = video.ParseTestSignals(cfg.VITS)
+		if err != nil {
+			log.Fatalf("Invalid -vits: %v", err)
+		}
+		for line, sig := range testLines {
+			if err := videoStandard.AddInserter(sig, line); err != nil {
+				log.Fatalf("Cannot put a test signal on line %d: %v", line, err)
+			}
+		}
+		log.Printf("Test signals on %d VBI lines", len(testLines))
+	}
+
+	// 16:9 anamorphic, signalled with WSS on 625-line systems and CGMS-A on 525-line
+	if cfg.Widescreen {
+		switch params.LinesPerFrame {
+		case 625:
+			err = videoStandard.AddInserter(&video.WSSInserter{Widescreen: true}, video.WSSLine(params))
+		case 525:
+			err = videoStandard.AddInserter(&video.CGMSInserter{Widescreen: true}, video.CGMSLines(params)...)
+		default:
+			log.Printf("No aspect ratio signalling for %d-line standards; set the receiver to 16:9 by hand", params.LinesPerFrame)
+		}
+		if err != nil {
+			log.Fatalf("Cannot insert aspect ratio signalling: %v", err)
+		}
+		log.Println("Widescreen: 16:9 anamorphic")
 	}
 
-	// 3. Set up the video source (test pattern or FFmpeg)
+	// Vertical interval timecode and on-screen burn-in from the frame counter
+	if cfg.VITC {
+		lines := video.DefaultVITCLines(params)
+		if cfg.VITCLines != "" {
+			lines, err = config.ParseLines(cfg.VITCLines)
+			if err != nil {
+				log.Fatalf("Invalid -vitc-lines: %v", err)
+			}
+			if len(lines) != 2 {
+				log.Fatalf("Invalid -vitc-lines: want two line numbers, e.g. 14,16")
+			}
+		}
+		if err := videoStandard.AddInserter(video.VITCInserter{}, video.VITCLines(params, lines)...); err != nil {
+			log.Fatalf("Cannot insert VITC: %v", err)
+		}
+		log.Printf("VITC on lines %v", video.VITCLines(params, lines))
+	}
+	videoStandard.SetTimecodeBurnIn(cfg.BurnIn)
+	videoStandard.SetFieldRateMotion(cfg.FieldRate)
+	interp, ok := video.Interpolations[cfg.Interp]
+	if !ok {
+		log.Fatalf("Unknown -interp %q (want nearest, linear, cubic or lanczos)", cfg.Interp)
+	}
+	videoStandard.SetInterpolation(interp)
+	videoStandard.SetWorkers(cfg.Workers)
+	videoStandard.SetFilters(video.FilterOptions{
+		Chroma:    cfg.ChromaLPF,
+		Luma:      cfg.LumaLPF,
+		LumaNotch: cfg.LumaNotch,
+	})
+
+	// Closed captions on line 21 (525-line only: NTSC, PAL-M and their 240p)
+	if cfg.Captions != "" || cfg.CCLive {
+		if params.LinesPerFrame != 525 && params.LinesPerFrame != 524 {
+			log.Fatalf("Closed captions need a 525-line standard, not %s", params.Name)
+		}
+		cc := captions.NewEncoder(cfg.Callsign)
+		if cfg.Captions != "" {
+			cues, err := captions.ParseFile(cfg.Captions)
+			if err != nil {
+				log.Fatalf("Failed to load captions: %v", err)
+			}
+			cc.LoadCues(cues)
+			log.Printf("Loaded %d caption cues from %s", len(cues), cfg.Captions)
+		}
+		if cfg.CCLive {
+			log.Println("Live captions: type a line and press Enter to send it.")
+			go cc.ReadLive(os.Stdin)
+		}
+		if err := videoStandard.AddInserter(video.NewCaptionInserter(cc), video.CaptionLines(params)...); err != nil {
+			log.Fatalf("Cannot insert captions: %v", err)
+		}
+	}
+
+	// Teletext pages and subtitles in the VBI (PAL only)
+	if cfg.Teletext != "" || cfg.Subtitles != "" {
+		if params.Colour != video.ColourPAL {
+			log.Fatalf("Teletext needs a PAL-family standard, not %s", params.Name)
+		}
+		var pages []*teletext.Page
+		if cfg.Teletext != "" {
+			pages, err = teletext.LoadDir(cfg.Teletext)
+			if err != nil {
+				log.Fatalf("Failed to load teletext pages: %v", err)
+			}
+			log.Printf("Loaded %d teletext pages from %s", len(pages), cfg.Teletext)
+		}
+		ttx := teletext.NewService(cfg.Callsign, pages)
+		if cfg.Subtitles != "" {
+			cues, err := captions.ParseFile(cfg.Subtitles)
+			if err != nil {
+				log.Fatalf("Failed to load subtitles: %v", err)
+			}
+			ttx.SetSubtitles(cues)
+			log.Printf("Loaded %d subtitles for page 888 from %s", len(cues), cfg.Subtitles)
+		}
+		var lines []int
+		if cfg.TTXLines != "" {
+			lines, err = config.ParseLines(cfg.TTXLines)
+			if err != nil {
+				log.Fatalf("Invalid -teletext-lines: %v", err)
+			}
+		} else {
+			// Leave the default lines that test signals, VITC or aspect
+			// signalling have claimed, or that a shorter progressive field
+			// has given over to picture.
+			lines = videoStandard.FreeLines(video.DefaultTeletextLines)
+		}
+		if err := videoStandard.AddInserter(video.NewTeletextInserter(ttx), lines...); err != nil {
+			log.Fatalf("Cannot insert teletext: %v", err)
+		}
+	}
+
+	// 3. Set up the video source (test pattern or FFmpeg). Frames are
+	// generated by the transmitter as it needs them; the source only updates
+	// the picture.
 	if cfg.Test {
 		log.Println("Test mode: SMPTE color bars will be transmitted.")
 		videoStandard.FillTestPattern()
-		go func() {
-			ticker := time.NewTicker(frameTick)
-			defer ticker.Stop()
-			for {
-				<-ticker.C
-				videoStandard.LockFrame()
-				videoStandard.GenerateFullFrame()
-				videoStandard.UnlockFrame()
-			}
-		}()
 	} else {
 		ffmpegCmd, err := source.StartFFmpegCapture(cfg, videoStandard)
 		if err != nil {
@@ -66,9 +208,6 @@ func main() {
 		}()
 	}
 
-	log.Println("Generating initial frame...")
-	videoStandard.GenerateFullFrame()
-
 	// 4. Start the SDR transmission using the opened device
 	if err := sdr.Transmit(dev, cfg, videoStandard); err != nil {
 		log.Fatalf("Transmission failed: %v", err)
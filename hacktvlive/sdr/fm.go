@@ -0,0 +1,130 @@
+package sdr
+
+import (
+	"fmt"
+	"math"
+
+	"hacktvlive/video"
+)
+
+// emphasis is a first-order pre-emphasis network, a shelf rising from its
+// zero to its pole, scaled to 0 dB at its crossover frequency.
+type emphasis struct {
+	zero, pole, crossover float64 // Hz
+}
+
+// emphases maps the names accepted by -preemphasis to their networks.
+var emphases = map[string]*emphasis{
+	"none": nil,
+	// CCIR Rec. 405, 625-line: 10 dB down at low frequencies, 14 dB range.
+	"405": {zero: 0.388e6, pole: 1.945e6, crossover: 1.5e6},
+	// CCIR Rec. 567, 525-line: 11 dB down at low frequencies, 13.8 dB range.
+	"567": {zero: 0.154e6, pole: 0.754e6, crossover: 0.76e6},
+}
+
+// emphasisFilter runs an emphasis network as a one-pole IIR filter, from the
+// bilinear transform of its analogue response warped to match at crossover.
+type emphasisFilter struct {
+	b0, b1, a1 float64
+	x1, y1     float64
+}
+
+func newEmphasisFilter(e *emphasis, sampleRate float64) *emphasisFilter {
+	// Gain at crossover of (1 + jf/zero) / (1 + jf/pole), to scale it to 0 dB.
+	r := func(f, corner float64) float64 { return 1 + (f/corner)*(f/corner) }
+	g := math.Sqrt(r(e.crossover, e.pole) / r(e.crossover, e.zero))
+
+	k := 2 * math.Pi * e.crossover / math.Tan(math.Pi*e.crossover/sampleRate)
+	wz, wp := 2*math.Pi*e.zero, 2*math.Pi*e.pole
+	den := 1 + k/wp
+	return &emphasisFilter{
+		b0: g * (1 + k/wz) / den,
+		b1: g * (1 - k/wz) / den,
+		a1: (1 - k/wp) / den,
+	}
+}
+
+func (f *emphasisFilter) step(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 - f.a1*f.y1
+	f.x1, f.y1 = x, y
+	return y
+}
+
+// Sound subcarriers are added to the video at about -16 dB relative to sync
+// tip to white, each frequency-modulated with a deviation of 50 kHz.
+const (
+	audioSubcarrierLevel     = 0.15
+	audioSubcarrierDeviation = 50e3
+)
+
+// audioSubcarrier is an FM sound carrier above the video. There is no audio
+// input yet, so it carries a test tone or, with no tone, just the carrier.
+type audioSubcarrier struct {
+	freq, tone       float64
+	phase, tonePhase float64
+	sampleRate       float64
+}
+
+func (a *audioSubcarrier) next() float64 {
+	v := audioSubcarrierLevel * math.Cos(a.phase)
+	a.tonePhase = math.Mod(a.tonePhase+2*math.Pi*a.tone/a.sampleRate, 2*math.Pi)
+	freq := a.freq + audioSubcarrierDeviation*math.Sin(a.tonePhase)
+	a.phase = math.Mod(a.phase+2*math.Pi*freq/a.sampleRate, 2*math.Pi)
+	return v
+}
+
+// fmModulator frequency-modulates the carrier with the composite signal, as
+// amateur FM-ATV does on 23 and 13 cm: sync tip below the carrier frequency
+// and peak white the deviation above it.
+type fmModulator struct {
+	mid, span   float64 // IRE halfway from sync tip to white, and the full range
+	deviation   float64 // Hz from sync tip to white
+	emphasis    *emphasisFilter
+	subcarriers []*audioSubcarrier
+	phase       float64
+	sampleRate  float64
+}
+
+// newFMModulator sets up FM with a peak-to-peak deviation in Hz, a
+// pre-emphasis network (nil for none) and sound subcarriers at the given
+// frequencies carrying a tone in Hz.
+func newFMModulator(p video.Params, deviation float64, e *emphasis, subcarriers []float64, tone, sampleRate float64) (*fmModulator, error) {
+	if deviation <= 0 {
+		return nil, fmt.Errorf("FM deviation must be positive")
+	}
+	m := &fmModulator{
+		mid:        (p.LevelSync + p.LevelWhite) / 2,
+		span:       p.LevelWhite - p.LevelSync,
+		deviation:  deviation,
+		sampleRate: sampleRate,
+	}
+	if e != nil {
+		m.emphasis = newEmphasisFilter(e, sampleRate)
+	}
+	for _, freq := range subcarriers {
+		if freq <= p.Bandwidth || freq >= sampleRate/2 {
+			return nil, fmt.Errorf("audio subcarrier at %.2f MHz must be above the %.2f MHz video and below the %.2f MHz Nyquist limit of the sample rate",
+				freq/1e6, p.Bandwidth/1e6, sampleRate/2e6)
+		}
+		m.subcarriers = append(m.subcarriers, &audioSubcarrier{freq: freq, tone: tone, sampleRate: sampleRate})
+	}
+	return m, nil
+}
+
+// modulate turns a field into samples of constant, full-scale amplitude. The
+// carrier phase runs on from field to field.
+func (m *fmModulator) modulate(out []complex64, field []float64) []complex64 {
+	for _, ire := range field {
+		x := (ire - m.mid) / m.span
+		if m.emphasis != nil {
+			x = m.emphasis.step(x)
+		}
+		for _, a := range m.subcarriers {
+			x += a.next()
+		}
+		m.phase = math.Mod(m.phase+2*math.Pi*x*m.deviation/m.sampleRate, 2*math.Pi)
+		sin, cos := math.Sincos(m.phase)
+		out = append(out, complex(float32(cos), float32(sin)))
+	}
+	return out
+}
@@ -1,57 +1,189 @@
 package sdr
 
 import (
+	"fmt"
 	"log"
 	"math"
 	"sync"
 
 	"github.com/samuel/go-hackrf/hackrf"
 	"hacktvlive/config"
+	"hacktvlive/dsp"
 	"hacktvlive/video"
 )
 
-// NewLowPassFilterTaps creates the coefficients (taps) for a FIR low-pass filter.
-// A Blackman window is used for good performance.
-func NewLowPassFilterTaps(numTaps int, bandwidth, sampleRate float64) []float64 {
-	taps := make([]float64, numTaps)
-	cutoffFreq := bandwidth / 2.0
-	normalizedCutoff := cutoffFreq / sampleRate
-
-	M := float64(numTaps - 1)
-	var sum float64
-	for i := 0; i < numTaps; i++ {
-		n := float64(i)
-		window := 0.42 - 0.5*math.Cos(2*math.Pi*n/M) + 0.08*math.Cos(4*math.Pi*n/M)
-
-		var sinc float64
-		if i == int(M/2) {
-			sinc = 2 * math.Pi * normalizedCutoff
-		} else {
-			sinc = math.Sin(2*math.Pi*normalizedCutoff*(n-M/2)) / (n - M/2)
-		}
+var debugLogOnce sync.Once
+
+// fieldQueue is how many generated fields may wait for the transmitter.
+const fieldQueue = 4
 
-		taps[i] = sinc * window
-		sum += taps[i]
+// modulator turns a field of the composite signal, in IRE, into complex
+// baseband samples, centred on the carrier and with magnitude 1 at full
+// scale, appended to out. Its state runs on from field to field.
+type modulator interface {
+	modulate(out []complex64, field []float64) []complex64
+}
+
+// generateFields renders fields one after another, modulates them, mixes
+// them up to the IF offset with nco into buffers taken from free and hands
+// them to the transmitter in order. Every field is sent once, so the
+// subcarrier phase and colour framing run on unbroken, and each field picks
+// up the newest picture from the source.
+func generateFields(v video.Standard, mod modulator, nco dsp.NCO, fields chan<- []byte, free <-chan []byte) {
+	var iq []complex64
+	for buf := range free {
+		v.LockFrame()
+		v.GenerateField()
+		iq = mod.modulate(iq[:0], v.FrameBuffer())
+		v.UnlockFrame()
+		fields <- mixToBytes(buf[:0], iq, &nco)
 	}
+}
 
-	// Normalize the taps to have a gain of 1 at DC (0 Hz)
-	for i := range taps {
-		taps[i] /= sum
+// mixToBytes mixes baseband samples up by the NCO's frequency and appends
+// them to out as interleaved 8-bit I and Q.
+func mixToBytes(out []byte, iq []complex64, nco *dsp.NCO) []byte {
+	for _, s := range iq {
+		sin, cos := nco.Next()
+		re, im := float64(real(s)), float64(imag(s))
+		out = append(out, toInt8(re*cos-im*sin), toInt8(re*sin+im*cos))
 	}
-	return taps
+	return out
 }
 
-var debugLogOnce sync.Once
+// toInt8 scales a sample to a signed byte, clipping at full scale.
+func toInt8(x float64) byte {
+	return byte(int8(math.Max(-127, math.Min(127, math.Round(x*127)))))
+}
+
+// resampledModulator resamples fields from the rate they are rendered at to
+// the HackRF's before modulating them.
+type resampledModulator struct {
+	resampler *dsp.Resampler
+	mod       modulator
+	field     []float64
+}
+
+func (m *resampledModulator) modulate(out []complex64, field []float64) []complex64 {
+	m.field = m.resampler.Process(m.field[:0], field)
+	return m.mod.modulate(out, m.field)
+}
+
+// newModulator sets up the modulation chosen with -mod at the HackRF's sample
+// rate, and describes it for the log. Video rendered at another rate is
+// resampled on the way in, kept flat as far as the modulation carries it.
+func newModulator(cfg *config.Config, v video.Standard, sampleRate float64) (modulator, string, error) {
+	var (
+		mod      modulator
+		desc     string
+		passband float64
+	)
+	switch cfg.Modulation {
+	case "am":
+		vestige, upper, err := channelEdges(v.Params(), cfg.Bandwidth*1e6, cfg.Offset*1e6, sampleRate)
+		if err != nil {
+			return nil, "", err
+		}
+		mod = newVSBModulator(v.IreToAmplitude, vestige, upper, sampleRate)
+		desc = fmt.Sprintf("AM VSB from %.2f MHz below to %.2f MHz above the carrier", vestige/1e6, upper/1e6)
+		passband = upper
+	case "fm":
+		e, ok := emphases[cfg.PreEmphasis]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown pre-emphasis %q (want none, 405 or 567)", cfg.PreEmphasis)
+		}
+		subcarriers, err := config.ParseFrequencies(cfg.AudioSubcarriers)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid audio subcarriers: %w", err)
+		}
+		if mod, err = newFMModulator(v.Params(), cfg.Deviation*1e6, e, subcarriers, cfg.AudioTone, sampleRate); err != nil {
+			return nil, "", err
+		}
+		desc = fmt.Sprintf("FM with %.2f MHz deviation, %s pre-emphasis and %d sound subcarriers",
+			cfg.Deviation, cfg.PreEmphasis, len(subcarriers))
+		passband = v.Params().Bandwidth
+	default:
+		return nil, "", fmt.Errorf("unknown modulation %q (want am or fm)", cfg.Modulation)
+	}
+	// reach is the highest video frequency that gets through: the top of the
+	// upper sideband on AM, and on either modulation half the slower of the
+	// render and HackRF rates.
+	reach := sampleRate / 2
+	if cfg.Modulation == "am" {
+		reach = math.Min(reach, passband)
+	}
+	if v.SampleRate() != sampleRate {
+		mod = &resampledModulator{
+			resampler: dsp.NewResampler(v.SampleRate(), sampleRate, passband),
+			mod:       mod,
+		}
+		reach = math.Min(reach, v.SampleRate()/2)
+	}
+	if p := v.Params(); p.Colour != video.ColourNone && reach < p.Fsc+chromaSideband {
+		effect := "colour will be weak"
+		if reach < p.Fsc {
+			effect = "the colour subcarrier is cut off and the picture will be black and white"
+		}
+		log.Printf("WARNING: only video up to %.2f MHz gets through, short of the %.2f MHz %s needs for colour: "+
+			"%s. Raise -sample-rate, or -bw on AM, to carry it.",
+			reach/1e6, (p.Fsc+chromaSideband)/1e6, p.Name, effect)
+	}
+	return mod, desc, nil
+}
+
+// chromaSideband is how far above the colour subcarrier the video must reach
+// for colour to come through: both sidebands of the narrowest chroma signal,
+// NTSC's 0.4 MHz Q.
+const chromaSideband = 0.4e6
+
+// channelEdges returns how far below and above the vision carrier the
+// transmitted channel reaches. A bandwidth of 0 takes the standard's vestige
+// plus its video bandwidth. Moved by the IF offset, the channel is held
+// inside the sampled band, clear of the edges where it would wrap round onto
+// itself; the upper sideband gives way if there is not room for it all.
+func channelEdges(p video.Params, bandwidth, offset, sampleRate float64) (vestige, upper float64, err error) {
+	vestige = p.Vestige
+	if bandwidth == 0 {
+		bandwidth = p.Vestige + p.Bandwidth
+	}
+	if bandwidth <= vestige {
+		return 0, 0, fmt.Errorf("channel bandwidth %.2f MHz leaves nothing above the %.2f MHz vestige", bandwidth/1e6, vestige/1e6)
+	}
+	limit := sampleRate/2 - vsbTransition/2
+	if offset-vestige < -limit {
+		return 0, 0, fmt.Errorf("an IF offset of %.2f MHz puts the vestige past the edge of the %.2f MHz sampled band", offset/1e6, sampleRate/1e6)
+	}
+	upper = math.Min(bandwidth-vestige, limit-offset)
+	if upper <= 0 {
+		return 0, 0, fmt.Errorf("an IF offset of %.2f MHz leaves no room for the upper sideband in the %.2f MHz sampled band", offset/1e6, sampleRate/1e6)
+	}
+	return vestige, upper, nil
+}
+
+// The HackRF's DAC runs from 2 to 20 Msps.
+const (
+	minSampleRate = 2e6
+	maxSampleRate = 20e6
+)
 
 // Transmit configures an open HackRF device and starts the transmission stream.
 func Transmit(dev *hackrf.Device, cfg *config.Config, v video.Standard) error {
-	txFrequencyHz := uint64(cfg.Frequency * 1_000_000)
+	sampleRate := cfg.SampleRate * 1e6
+	if sampleRate < minSampleRate || sampleRate > maxSampleRate {
+		return fmt.Errorf("sample rate %.3f Msps is outside the HackRF's %.0f-%.0f Msps", cfg.SampleRate, minSampleRate/1e6, maxSampleRate/1e6)
+	}
+	// The HackRF is tuned the IF offset below the carrier, and the signal
+	// mixed up by as much, so its LO leakage falls away from the carrier.
+	if cfg.Offset >= cfg.Frequency {
+		return fmt.Errorf("IF offset %.3f MHz must be below the %.3f MHz transmit frequency", cfg.Offset, cfg.Frequency)
+	}
+	carrierHz := cfg.Frequency * 1e6
+	tuneHz := uint64(math.Round(carrierHz - cfg.Offset*1e6))
 
-	if err := dev.SetFreq(txFrequencyHz); err != nil {
+	if err := dev.SetFreq(tuneHz); err != nil {
 		return err
 	}
-	// Use the fixed sample rate from the config package
-	if err := dev.SetSampleRate(config.FixedSampleRate); err != nil {
+	if err := dev.SetSampleRate(sampleRate); err != nil {
 		return err
 	}
 	if err := dev.SetTXVGAGain(cfg.Gain); err != nil {
@@ -61,35 +193,45 @@ func Transmit(dev *hackrf.Device, cfg *config.Config, v video.Standard) error {
 		return err
 	}
 
-	log.Printf("Starting transmission on %.3f MHz with a %.2f MHz filter bandwidth (Sample Rate: %.1f Msps)...",
-		float64(txFrequencyHz)/1e6, cfg.Bandwidth, config.FixedSampleRate/1e6)
+	mod, desc, err := newModulator(cfg, v, sampleRate)
+	if err != nil {
+		return err
+	}
+	log.Printf("Starting transmission on %.3f MHz, %s (HackRF tuned to %.3f MHz, Sample Rate: %.1f Msps)...",
+		carrierHz/1e6, desc, float64(tuneHz)/1e6, cfg.SampleRate)
+
+	fields := make(chan []byte, fieldQueue)
+	free := make(chan []byte, fieldQueue+1)
+	for i := 0; i < fieldQueue+1; i++ {
+		free <- nil
+	}
+	go generateFields(v, mod, dsp.NewNCO(0, cfg.Offset*1e6, sampleRate), fields, free)
 
-	var sampleCounter int = 0
+	fieldBuf := <-fields
+	sent := 0
 	// StartTX is non-blocking and returns immediately.
-	// The callback is now simple again, only sending pre-filtered samples.
+	// The callback only copies out IQ; fields are generated ahead of it.
 	return dev.StartTX(func(buf []byte) error {
-		samplesToWrite := len(buf) / 2
-
-		v.RLockFrame()
-		defer v.RUnlockFrame()
-
-		frameBuf := v.FrameBuffer()
-
-		for i := 0; i < samplesToWrite; i++ {
-			ire := frameBuf[sampleCounter]
-			amplitude := v.IreToAmplitude(ire)
-
-			iSample := int8(amplitude * 127.0)
-			qSample := int8(0)
-
-			buf[i*2] = byte(iSample)
-			buf[i*2+1] = byte(qSample)
-
-			sampleCounter++
-			if sampleCounter >= len(frameBuf) {
-				sampleCounter = 0
+		for len(buf) > 0 {
+			if sent >= len(fieldBuf) {
+				select {
+				case next := <-fields:
+					free <- fieldBuf
+					fieldBuf = next
+				default:
+					// Generation has fallen behind: repeat the field rather
+					// than stall, at the cost of an interlace and colour
+					// framing glitch.
+					debugLogOnce.Do(func() {
+						log.Println("Field generation is falling behind the transmitter; repeating fields")
+					})
+				}
+				sent = 0
 			}
+			n := copy(buf, fieldBuf[sent:])
+			buf = buf[n:]
+			sent += n
 		}
 		return nil
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,65 @@
+package sdr
+
+import (
+	"math"
+
+	"hacktvlive/dsp"
+)
+
+// vsbTransition is the width of the filter skirts: the lower sideband is
+// gone 0.5 MHz beyond the edge of the vestige, as System M and B require.
+const vsbTransition = 0.5e6
+
+// vsbHeadroom is how far the filtered signal is scaled down to keep it inside
+// full scale. It overshoots peak carrier by about 4% at sync edges.
+const vsbHeadroom = 1.05
+
+// vsbModulator turns the vision carrier amplitude into a complex baseband
+// vestigial sideband signal: the upper sideband up to the top of the channel
+// and a vestige of the lower one, in place of the two full sidebands of
+// plain AM. The filter runs on from one call to the next, so there is no
+// seam at field boundaries.
+type vsbModulator struct {
+	amplitude func(ire float64) float64
+	re, im    []float64 // complex band-pass taps, symmetric and antisymmetric
+	history   []float64 // carrier amplitudes still inside the filter
+	window    []float64 // history followed by the new field
+}
+
+// newVSBModulator designs the filter for a channel running from vestige Hz
+// below the carrier to upper Hz above it. amplitude maps IRE to carrier
+// amplitude, 0 to 1.
+func newVSBModulator(amplitude func(float64) float64, vestige, upper, sampleRate float64) *vsbModulator {
+	// A Blackman-windowed filter falls from -6 dB to -70 dB over about 2.8
+	// times the sample rate divided by its length.
+	numTaps := 2*int(math.Ceil(1.4*sampleRate/vsbTransition)) + 1
+	m := &vsbModulator{amplitude: amplitude, history: make([]float64, numTaps-1)}
+	m.re, m.im = dsp.NewComplexBandPassTaps(numTaps, -vestige, upper, sampleRate)
+	return m
+}
+
+// modulate amplitude-modulates a field, filters it and appends it to out.
+// The output lags the input by half the filter.
+func (m *vsbModulator) modulate(out []complex64, field []float64) []complex64 {
+	m.window = append(m.window[:0], m.history...)
+	for _, ire := range field {
+		m.window = append(m.window, m.amplitude(ire))
+	}
+	half := len(m.re) / 2
+	for n := range field {
+		// The taps are folded about the centre: the real part pairs the
+		// samples either side by their sum, the imaginary part by their
+		// difference.
+		w := m.window[n : n+len(m.re)]
+		i := m.re[half] * w[half]
+		var q float64
+		for k := 1; k <= half; k++ {
+			before, after := w[half-k], w[half+k]
+			i += m.re[half+k] * (before + after)
+			q += m.im[half+k] * (before - after)
+		}
+		out = append(out, complex(float32(i/vsbHeadroom), float32(q/vsbHeadroom)))
+	}
+	copy(m.history, m.window[len(field):])
+	return out
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os/exec"
 	"runtime"
+	"strconv"
 
 	"hacktvlive/config"
 	"hacktvlive/video"
@@ -38,16 +39,24 @@ func StartFFmpegCapture(cfg *config.Config, v video.Standard) (*exec.Cmd, error)
 		return nil, fmt.Errorf("unsupported OS: %s", runtime.GOOS)
 	}
 
-	fpsVal := "30000/1001"
-	if cfg.PAL {
-		fpsVal = "25"
+	fpsVal := strconv.FormatFloat(v.SourceRate(), 'f', -1, 64)
+	width, height := v.RasterSize()
+
+	// In widescreen mode the picture is cropped to 16:9 and the overlay drawn
+	// at that shape, then squeezed into the frame for anamorphic transmission.
+	scale := fmt.Sprintf("scale=%d:%d", width, height)
+	if cfg.Widescreen {
+		scale = fmt.Sprintf("crop=w=min(iw\\,ih*16/9):h=min(ih\\,iw*9/16),scale=%d:%d", height*16/9, height)
 	}
 
 	var vfArg string
 	if cfg.Callsign != "" {
-		vfArg = fmt.Sprintf("scale=%d:%d,fps=%s,drawbox=x=0:y=ih-40:w=iw:h=40:color=black@0.6:t=fill,drawtext=fontfile=/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf:text='%s':x=10:y=h-35:fontcolor=white:fontsize=32:borderw=2:bordercolor=black", video.FrameWidth, video.FrameHeight, fpsVal, cfg.Callsign)
+		vfArg = fmt.Sprintf("%s,fps=%s,drawbox=x=0:y=ih-40:w=iw:h=40:color=black@0.6:t=fill,drawtext=fontfile=/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf:text='%s':x=10:y=h-35:fontcolor=white:fontsize=32:borderw=2:bordercolor=black", scale, fpsVal, cfg.Callsign)
 	} else {
-		vfArg = fmt.Sprintf("scale=%d:%d,fps=%s", video.FrameWidth, video.FrameHeight, fpsVal)
+		vfArg = fmt.Sprintf("%s,fps=%s", scale, fpsVal)
+	}
+	if cfg.Widescreen {
+		vfArg += fmt.Sprintf(",scale=%d:%d", width, height)
 	}
 
 	commonArgs := []string{
@@ -71,12 +80,11 @@ func StartFFmpegCapture(cfg *config.Config, v video.Standard) (*exec.Cmd, error)
 	log.Println("FFmpeg process started to capture webcam...")
 
 	go func() {
+		// Read each frame into a private buffer so the frame generator is
+		// never kept waiting on FFmpeg, then copy it in under the lock.
+		frame := make([]byte, len(v.RawFrameBuffer()))
 		for {
-			// Lock the raw buffer before writing to prevent a data race.
-			v.LockRaw()
-			_, err := io.ReadFull(ffmpegStdout, v.RawFrameBuffer())
-			v.UnlockRaw() // Always unlock, even after an error.
-
+			_, err := io.ReadFull(ffmpegStdout, frame)
 			if err != nil {
 				if err != io.EOF {
 					log.Printf("Error reading from FFmpeg: %v", err)
@@ -84,10 +92,10 @@ func StartFFmpegCapture(cfg *config.Config, v video.Standard) (*exec.Cmd, error)
 				break
 			}
 
-			// Generate the full analog signal frame from the new raw data.
-			v.LockFrame()
-			v.GenerateFullFrame()
-			v.UnlockFrame()
+			// Lock the raw buffer before writing to prevent a data race.
+			v.LockRaw()
+			copy(v.RawFrameBuffer(), frame)
+			v.UnlockRaw()
 		}
 	}()
 
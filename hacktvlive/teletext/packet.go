@@ -0,0 +1,105 @@
+package teletext
+
+// PacketSize is the length of a WST packet after the clock run-in and framing
+// code: two address bytes followed by 40 data bytes.
+const PacketSize = 42
+
+// hamming84 encodes a nibble with the Hamming 8/4 code used for addresses
+// and control data.
+var hamming84 = [16]byte{
+	0x15, 0x02, 0x49, 0x5E, 0x64, 0x73, 0x38, 0x2F,
+	0xD0, 0xC7, 0x8C, 0x9B, 0xA1, 0xB6, 0xFD, 0xEA,
+}
+
+// Page header control bits, numbered as in ETS 300 706.
+const (
+	ControlErase      = 1 << 4  // C4: erase page
+	ControlNewsflash  = 1 << 5  // C5
+	ControlSubtitle   = 1 << 6  // C6
+	ControlSuppress   = 1 << 7  // C7: suppress header
+	ControlSerialMode = 1 << 11 // C11: magazines sent serially
+)
+
+// oddParity sets bit 7 so the byte has odd parity.
+func oddParity(b byte) byte {
+	b &= 0x7F
+	ones := 0
+	for v := b; v != 0; v >>= 1 {
+		ones += int(v & 1)
+	}
+	if ones%2 == 0 {
+		b |= 0x80
+	}
+	return b
+}
+
+// address returns the magazine and row address group for a packet. Magazine
+// 8 is sent as 0.
+func address(magazine, row int) [2]byte {
+	return [2]byte{
+		hamming84[(magazine&7)|(row&1)<<3],
+		hamming84[(row>>1)&0xF],
+	}
+}
+
+// headerPacket builds packet 0 of a page: page number, subcode and control
+// bits, followed by 32 characters of header text.
+func headerPacket(page, subcode, control int, text string) []byte {
+	pkt := make([]byte, 0, PacketSize)
+	addr := address(page>>8, 0)
+	pkt = append(pkt, addr[0], addr[1],
+		hamming84[page&0xF],
+		hamming84[(page>>4)&0xF],
+		hamming84[subcode&0xF],
+		hamming84[(subcode>>4)&0x7|(control>>4&1)<<3],
+		hamming84[(subcode>>8)&0xF],
+		hamming84[(subcode>>12)&0x3|(control>>5&3)<<2],
+		hamming84[(control>>7)&0xF],
+		hamming84[(control>>11)&0xF],
+	)
+	return append(pkt, displayBytes([]byte(text), 32)...)
+}
+
+// rowPacket builds a display row packet (rows 1-24) of 40 characters.
+func rowPacket(magazine, row int, data []byte) []byte {
+	addr := address(magazine, row)
+	return append([]byte{addr[0], addr[1]}, displayBytes(data, 40)...)
+}
+
+// linksPacket builds packet 27/0, the editorial links used by Fastext keys.
+// Unused links point at page FF, which decoders treat as "no page".
+func linksPacket(page int, links [6]int) []byte {
+	magazine := page >> 8
+	addr := address(magazine, 27)
+	pkt := []byte{addr[0], addr[1], hamming84[0]}
+	for _, link := range links {
+		if link == 0 {
+			link = magazine<<8 | 0xFF
+		}
+		// The link's magazine is sent relative to the current magazine.
+		rel := (link>>8 ^ magazine) & 7
+		pkt = append(pkt,
+			hamming84[link&0xF],
+			hamming84[(link>>4)&0xF],
+			hamming84[0xF],
+			hamming84[0x7|(rel&1)<<3],
+			hamming84[0xF],
+			hamming84[0x3|(rel>>1)<<2],
+		)
+	}
+	// Link control byte, then the two page CRC bytes (not checked by TVs).
+	return append(pkt, hamming84[0xF], 0, 0)
+}
+
+// displayBytes pads or truncates data to n characters with odd parity.
+func displayBytes(data []byte, n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		c := byte(' ')
+		if i < len(data) {
+			c = data[i]
+		}
+		out[i] = oddParity(c)
+	}
+	return out
+}
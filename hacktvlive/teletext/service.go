@@ -0,0 +1,209 @@
+package teletext
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"hacktvlive/captions"
+)
+
+// SubtitlePage is the page that carries subtitles.
+const SubtitlePage = 0x888
+
+// subtitleWidth is the longest subtitle row that fits between the double
+// height and box control codes.
+const subtitleWidth = 34
+
+// Service is a teletext inserter: a carousel of pages plus optional subtitles
+// on page 888. Subtitle updates jump the queue so they stay in sync, and the
+// subtitle on air takes a turn in the carousel after the last page, so a
+// receiver that tunes in or misses an update still picks it up.
+type Service struct {
+	mu       sync.Mutex
+	callsign string
+	pages    []*Page
+	numbers  []int
+	nextPage int
+	queue    [][]byte
+	subtitle [][]byte // page 888 as last updated, nil before the first cue
+	start    time.Time
+	cues     []captions.Cue
+	nextCue  int
+	clearAt  time.Duration
+}
+
+// NewService creates a teletext service that broadcasts pages in number
+// order. Subpages sharing a number rotate according to their cycle time.
+func NewService(callsign string, pages []*Page) *Service {
+	s := &Service{callsign: callsign, pages: pages, clearAt: -1}
+	for _, p := range pages {
+		if len(s.numbers) == 0 || s.numbers[len(s.numbers)-1] != p.Number {
+			s.numbers = append(s.numbers, p.Number)
+		}
+	}
+	return s
+}
+
+// SetSubtitles schedules subtitle cues on page 888, timed from the first
+// packet sent.
+func (s *Service) SetSubtitles(cues []captions.Cue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cues = cues
+	s.nextCue = 0
+}
+
+// NextPacket returns the next 42-byte packet to send, or nil when there is
+// nothing to send on this line.
+func (s *Service) NextPacket() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.start.IsZero() {
+		s.start = now
+	}
+	if sub := s.subtitleUpdate(now.Sub(s.start)); sub != nil {
+		// Subtitles pre-empt whatever page was part way through.
+		s.queue = sub
+		s.subtitle = sub
+	}
+
+	if len(s.queue) == 0 && s.nextPage == len(s.numbers) {
+		s.queue = s.subtitle
+		s.nextPage = 0
+	}
+	if len(s.queue) == 0 && len(s.numbers) > 0 {
+		s.queue = s.pagePackets(s.currentSubpage(s.numbers[s.nextPage], now.Sub(s.start)), now)
+		s.nextPage++
+	}
+	if len(s.queue) == 0 {
+		return nil
+	}
+	pkt := s.queue[0]
+	s.queue = s.queue[1:]
+	return pkt
+}
+
+// currentSubpage picks which subpage of a rotating page is on air.
+func (s *Service) currentSubpage(number int, elapsed time.Duration) *Page {
+	var subpages []*Page
+	var total time.Duration
+	for _, p := range s.pages {
+		if p.Number == number {
+			subpages = append(subpages, p)
+			total += p.Cycle
+		}
+	}
+	if len(subpages) == 1 || total == 0 {
+		return subpages[0]
+	}
+	pos := elapsed % total
+	for _, p := range subpages {
+		if pos < p.Cycle {
+			return p
+		}
+		pos -= p.Cycle
+	}
+	return subpages[0]
+}
+
+// pagePackets builds the header, display rows and Fastext links of a page.
+func (s *Service) pagePackets(p *Page, now time.Time) [][]byte {
+	header := fmt.Sprintf("%-8.8s%03X %s", s.callsign, p.Number, now.Format("Mon 02 Jan 15:04:05"))
+	packets := [][]byte{headerPacket(p.Number, p.Subcode, p.Control, header)}
+	for row := 1; row <= 24; row++ {
+		if p.Rows[row] != nil {
+			packets = append(packets, rowPacket(p.Number>>8, row, p.Rows[row]))
+		}
+	}
+	if p.Links != [6]int{} {
+		packets = append(packets, linksPacket(p.Number, p.Links))
+	}
+	return packets
+}
+
+// subtitleUpdate returns the packets for page 888 when a cue starts or ends.
+func (s *Service) subtitleUpdate(now time.Duration) [][]byte {
+	if s.nextCue < len(s.cues) && now >= s.cues[s.nextCue].Start {
+		cue := s.cues[s.nextCue]
+		s.nextCue++
+		s.clearAt = cue.End
+		return subtitlePackets(cue.Text)
+	}
+	if s.clearAt >= 0 && now >= s.clearAt {
+		s.clearAt = -1
+		return subtitlePackets("")
+	}
+	return nil
+}
+
+// subtitlePackets builds page 888 with the text boxed in double height rows
+// at the bottom of the screen. An empty text clears the subtitle.
+func subtitlePackets(text string) [][]byte {
+	control := ControlErase | ControlSubtitle | ControlSuppress | ControlSerialMode
+	packets := [][]byte{headerPacket(SubtitlePage, 0, control, "")}
+
+	rows := wrap(text, subtitleWidth)
+	if len(rows) > 3 {
+		rows = rows[len(rows)-3:]
+	}
+	for i, text := range rows {
+		pad := (subtitleWidth - len(text)) / 2
+		data := []byte(strings.Repeat(" ", pad))
+		data = append(data, 0x0D, 0x0B, 0x0B)
+		data = append(data, toG0(text)...)
+		data = append(data, 0x0A, 0x0A)
+		row := 23 - 2*(len(rows)-1-i)
+		packets = append(packets, rowPacket(SubtitlePage>>8, row, data))
+	}
+	return packets
+}
+
+// toG0 maps text onto the English G0 character set, replacing anything it
+// cannot show.
+func toG0(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '£':
+			out = append(out, 0x23)
+		case r == '#':
+			out = append(out, 0x5F)
+		case r >= 0x20 && r < 0x7F:
+			out = append(out, byte(r))
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// wrap splits text into rows no wider than width.
+func wrap(text string, width int) []string {
+	var rows []string
+	for _, line := range strings.Split(text, "\n") {
+		var row string
+		for _, word := range strings.Fields(line) {
+			switch {
+			case row == "":
+				row = word
+			case len(row)+1+len(word) <= width:
+				row += " " + word
+			default:
+				rows = append(rows, row)
+				row = word
+			}
+			for len(row) > width {
+				rows = append(rows, row[:width])
+				row = row[width:]
+			}
+		}
+		if row != "" {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
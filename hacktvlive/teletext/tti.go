@@ -0,0 +1,156 @@
+package teletext
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page is one teletext page (or subpage) ready to be broadcast.
+type Page struct {
+	Number  int // magazine and page, e.g. 0x100
+	Subcode int
+	Control int
+	Cycle   time.Duration // how long each subpage is shown in a rotation
+	Rows    [25][]byte    // display rows 1-24; row 0 is generated by the header
+	Links   [6]int        // Fastext links, 0 when unused
+}
+
+// defaultCycle is used when a TTI file gives no CT line.
+const defaultCycle = 8 * time.Second
+
+// LoadDir reads every .tti file in dir. A file may hold several subpages,
+// each starting at a PN line.
+func LoadDir(dir string) ([]*Page, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tti"))
+	if err != nil {
+		return nil, err
+	}
+	upper, _ := filepath.Glob(filepath.Join(dir, "*.TTI"))
+	paths = append(paths, upper...)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .tti files found in %s", dir)
+	}
+
+	var pages []*Page
+	for _, path := range paths {
+		filePages, err := loadTTI(path)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, filePages...)
+	}
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Number < pages[j].Number })
+	return pages, nil
+}
+
+// loadTTI parses a single TTI file. Only the commands needed for broadcast
+// are interpreted: PN, SC, CT, OL and FL.
+func loadTTI(path string) ([]*Page, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open teletext page: %w", err)
+	}
+	defer f.Close()
+
+	var pages []*Page
+	var cur *Page
+	cycle := defaultCycle
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		cmd, arg, ok := strings.Cut(line, ",")
+		if !ok {
+			continue
+		}
+		fail := func(format string, a ...any) error {
+			return fmt.Errorf("%s:%d: %s", path, lineNo, fmt.Sprintf(format, a...))
+		}
+
+		if cmd == "PN" {
+			// PN,mppss: magazine, page and subpage, page in hex.
+			if len(arg) < 3 {
+				return nil, fail("bad page number %q", arg)
+			}
+			num, err := strconv.ParseInt(arg[:3], 16, 32)
+			if err != nil || num < 0x100 || num > 0x8FF {
+				return nil, fail("bad page number %q", arg)
+			}
+			cur = &Page{Number: int(num), Control: ControlSerialMode, Cycle: cycle}
+			pages = append(pages, cur)
+			continue
+		}
+		if cmd == "CT" {
+			secs, err := strconv.Atoi(strings.Split(arg, ",")[0])
+			if err != nil || secs <= 0 {
+				return nil, fail("bad cycle time %q", arg)
+			}
+			cycle = time.Duration(secs) * time.Second
+			if cur != nil {
+				cur.Cycle = cycle
+			}
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		switch cmd {
+		case "SC":
+			sc, err := strconv.ParseInt(arg, 16, 32)
+			if err != nil {
+				return nil, fail("bad subcode %q", arg)
+			}
+			cur.Subcode = int(sc) & 0x3F7F
+		case "OL":
+			num, text, ok := strings.Cut(arg, ",")
+			row, err := strconv.Atoi(num)
+			if !ok || err != nil || row < 1 || row > 24 {
+				continue
+			}
+			cur.Rows[row] = decodeRow(text)
+		case "FL":
+			for i, link := range strings.Split(arg, ",") {
+				if i >= len(cur.Links) {
+					break
+				}
+				if n, err := strconv.ParseInt(link, 16, 32); err == nil && n >= 0x100 && n <= 0x8FF {
+					cur.Links[i] = int(n)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%s: no PN line found", path)
+	}
+	return pages, nil
+}
+
+// decodeRow turns an OL line into raw 7-bit teletext characters. Control
+// codes may be written as ESC followed by the code plus 0x40, or as bytes
+// with the top bit set.
+func decodeRow(text string) []byte {
+	raw := []byte(text)
+	row := make([]byte, 0, 40)
+	for i := 0; i < len(raw) && len(row) < 40; i++ {
+		c := raw[i]
+		switch {
+		case c == 0x1B && i+1 < len(raw):
+			i++
+			row = append(row, (raw[i]-0x40)&0x7F)
+		default:
+			row = append(row, c&0x7F)
+		}
+	}
+	return row
+}
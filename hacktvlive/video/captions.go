@@ -0,0 +1,53 @@
+package video
+
+import "math"
+
+// CaptionSource supplies the EIA-608 byte pairs, parity included, sent on
+// line 21 of each field.
+type CaptionSource interface {
+	NextPair(field int) (byte, byte)
+}
+
+// EIA-608 line 21 timing: a clock run-in of 7 cycles at 32 times the line
+// rate starting 10.5 µs after the leading edge of sync, then start bits 001
+// and two data bytes, all at 50 IRE.
+const (
+	captionRunInStart = 10.5e-6
+	captionRunInBits  = 7
+	captionLevel      = 50.0
+)
+
+// CaptionInserter writes the line 21 waveform carrying one byte pair from
+// its source per field.
+type CaptionInserter struct {
+	src CaptionSource
+}
+
+// NewCaptionInserter creates an inserter for closed captions from src.
+func NewCaptionInserter(src CaptionSource) *CaptionInserter {
+	return &CaptionInserter{src: src}
+}
+
+// CaptionLines returns the lines that carry captions: the last blanking line
+// before the picture in each field (21 and 284 on 525-line systems).
+func CaptionLines(p Params) []int {
+	line := p.FirstActiveLine - 1
+	return []int{line, line + (p.LinesPerFrame+1)/2}
+}
+
+// InsertLine adds the clock run-in, start bits and data to the line.
+func (c *CaptionInserter) InsertLine(info LineInfo, lineBuffer []float64) {
+	p := info.Params
+	bitSamples := info.SampleRate / (32.0 * p.FrameRate * float64(p.LinesPerFrame))
+	runInStart := info.Sample(captionRunInStart)
+
+	runInEnd := runInStart + captionRunInBits*bitSamples
+	for s := int(math.Ceil(runInStart)); float64(s) < runInEnd; s++ {
+		phase := 2.0 * math.Pi * (float64(s) - runInStart) / bitSamples
+		lineBuffer[s] += captionLevel / 2.0 * (1.0 - math.Cos(phase))
+	}
+
+	b1, b2 := c.src.NextPair(info.Field)
+	bits := append([]bool{false, false, true}, byteBits(b1, b2)...)
+	writeNRZ(lineBuffer, bits, runInEnd, bitSamples, captionLevel, 1.0)
+}
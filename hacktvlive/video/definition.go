@@ -0,0 +1,265 @@
+package video
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// definition is the on-disk JSON form of a Params table. Durations are given
+// in microseconds and levels in IRE.
+type definition struct {
+	Name             string   `json:"name"`
+	Colour           string   `json:"colour"`
+	FrameRate        float64  `json:"frame_rate"`
+	Lines            int      `json:"lines"`
+	ActiveLines      int      `json:"active_lines"`
+	FirstActiveLine  int      `json:"first_active_line"`
+	FieldOrder       string   `json:"field_order"`
+	Progressive      bool     `json:"progressive"`
+	RasterWidth      int      `json:"raster_width"`
+	RasterHeight     int      `json:"raster_height"`
+	FieldSyncLine    int      `json:"field_sync_line"`
+	EqualisingPulses int      `json:"equalising_pulses"`
+	BroadPulses      int      `json:"broad_pulses"`
+	Subcarrier       float64  `json:"subcarrier_hz"`
+	Bandwidth        float64  `json:"bandwidth_hz"`
+	Vestige          float64  `json:"vestige_hz"`
+	Modulation       string   `json:"modulation"`
+	ResidualCarrier  *float64 `json:"residual_carrier"`
+
+	Timing struct {
+		HSync        float64 `json:"hsync"`
+		BroadPulse   float64 `json:"broad_pulse"`
+		EqPulse      float64 `json:"eq_pulse"`
+		BurstStart   float64 `json:"burst_start"`
+		BurstLength  float64 `json:"burst_length"`
+		ActiveStart  float64 `json:"active_start"`
+		ActiveLength float64 `json:"active_length"`
+	} `json:"timing_us"`
+
+	Levels struct {
+		Sync     float64 `json:"sync"`
+		Blanking float64 `json:"blanking"`
+		Black    float64 `json:"black"`
+		White    float64 `json:"white"`
+		Burst    float64 `json:"burst"`
+	} `json:"levels"`
+}
+
+var colourSystems = map[string]ColourSystem{
+	"ntsc":  ColourNTSC,
+	"pal":   ColourPAL,
+	"secam": ColourSECAM,
+	"none":  ColourNone,
+}
+
+// LoadParams reads a user-defined video standard from a JSON definition file
+// and checks that it is self-consistent.
+func LoadParams(path string) (Params, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Params{}, fmt.Errorf("failed to open standard definition: %w", err)
+	}
+	defer f.Close()
+
+	var def definition
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		return Params{}, fmt.Errorf("failed to parse standard definition %s: %w", path, err)
+	}
+
+	colour, ok := colourSystems[strings.ToLower(def.Colour)]
+	if !ok {
+		return Params{}, fmt.Errorf("%s: unknown colour system %q (want ntsc, pal, secam or none)", path, def.Colour)
+	}
+	order := TopFieldFirst
+	if def.FieldOrder != "" {
+		if order, ok = FieldOrders[strings.ToLower(def.FieldOrder)]; !ok {
+			return Params{}, fmt.Errorf("%s: unknown field order %q (want tff or bff)", path, def.FieldOrder)
+		}
+	}
+	polarity := NegativeModulation
+	if def.Modulation != "" {
+		if polarity, ok = Polarities[strings.ToLower(def.Modulation)]; !ok {
+			return Params{}, fmt.Errorf("%s: unknown modulation %q (want negative or positive)", path, def.Modulation)
+		}
+	}
+	name := def.Name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	const us = 1e-6
+	p := Params{
+		Name:             name,
+		Colour:           colour,
+		FrameRate:        def.FrameRate,
+		LinesPerFrame:    def.Lines,
+		ActiveVideoLines: def.ActiveLines,
+		FirstActiveLine:  def.FirstActiveLine,
+		FieldOrder:       order,
+		Progressive:      def.Progressive,
+		RasterWidth:      def.RasterWidth,
+		RasterHeight:     def.RasterHeight,
+		FieldSyncLine:    def.FieldSyncLine,
+		EqualisingPulses: def.EqualisingPulses,
+		BroadPulses:      def.BroadPulses,
+		HSync:            def.Timing.HSync * us,
+		BroadPulse:       def.Timing.BroadPulse * us,
+		EqPulse:          def.Timing.EqPulse * us,
+		BurstStart:       def.Timing.BurstStart * us,
+		BurstLength:      def.Timing.BurstLength * us,
+		ActiveStart:      def.Timing.ActiveStart * us,
+		ActiveLength:     def.Timing.ActiveLength * us,
+		Fsc:              def.Subcarrier,
+		Bandwidth:        def.Bandwidth,
+		Vestige:          def.Vestige,
+		Polarity:         polarity,
+		LevelSync:        def.Levels.Sync,
+		LevelBlanking:    def.Levels.Blanking,
+		LevelBlack:       def.Levels.Black,
+		LevelWhite:       def.Levels.White,
+		BurstAmplitude:   def.Levels.Burst,
+	}
+	if colour == ColourSECAM {
+		p.Fsc = secamForDb
+	}
+	// The raster defaults to 720 pixels wide with a row for every active line.
+	if p.RasterWidth == 0 {
+		p.RasterWidth = 720
+	}
+	if p.RasterHeight == 0 {
+		p.RasterHeight = p.ActiveVideoLines
+	}
+	// Most systems keep 0.75 MHz of the lower sideband. Negative modulation
+	// leaves 12.5% carrier at white for intercarrier sound; positive
+	// modulation takes sync tips right down to no carrier.
+	if p.Vestige == 0 {
+		p.Vestige = 0.75e6
+	}
+	switch {
+	case def.ResidualCarrier != nil:
+		p.ResidualCarrier = *def.ResidualCarrier
+	case polarity == NegativeModulation:
+		p.ResidualCarrier = 0.125
+	}
+
+	if err := p.Validate(); err != nil {
+		return Params{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Validate checks that the parameter table describes a signal that can
+// actually be generated: positive rates, a field layout that fits in the
+// frame, and line timings that fit in the line.
+func (p Params) Validate() error {
+	if p.FrameRate <= 0 {
+		return fmt.Errorf("frame rate must be positive, got %g", p.FrameRate)
+	}
+	if p.LinesPerFrame < 2 {
+		return fmt.Errorf("need at least 2 lines per frame, got %d", p.LinesPerFrame)
+	}
+	if p.ActiveVideoLines <= 0 {
+		return fmt.Errorf("active line count must be positive, got %d", p.ActiveVideoLines)
+	}
+	if p.EqualisingPulses < 0 || p.BroadPulses <= 0 {
+		return fmt.Errorf("field sync needs at least one broad pulse and no negative equalising pulses")
+	}
+	if 2*p.EqualisingPulses+p.BroadPulses >= p.LinesPerFrame {
+		return fmt.Errorf("field sync (%d half-lines) does not fit in a %d-half-line field",
+			2*p.EqualisingPulses+p.BroadPulses, p.LinesPerFrame)
+	}
+	if p.FieldSyncLine < 1 || p.FieldSyncLine > p.LinesPerFrame {
+		return fmt.Errorf("field sync line %d is outside the frame", p.FieldSyncLine)
+	}
+	if p.RasterWidth <= 0 || p.RasterHeight <= 0 {
+		return fmt.Errorf("raster must be at least 1x1 pixels, got %dx%d", p.RasterWidth, p.RasterHeight)
+	}
+	if p.FirstActiveLine < 1 {
+		return fmt.Errorf("first active line must be at least 1, got %d", p.FirstActiveLine)
+	}
+
+	if p.Progressive && p.LinesPerFrame%2 != 0 {
+		return fmt.Errorf("a progressive standard needs two whole fields per frame, not %d lines", p.LinesPerFrame)
+	}
+	top, bottom := p.pictureStart()
+	last := max(top+(p.ActiveVideoLines+1)/2, bottom+p.ActiveVideoLines/2) - 1
+	if p.Progressive {
+		last = bottom + p.ActiveVideoLines - 1
+	}
+	if last > p.LinesPerFrame {
+		return fmt.Errorf("%d active lines starting at line %d run past the end of the %d-line frame (field 2 ends on line %d)",
+			p.ActiveVideoLines, p.FirstActiveLine, p.LinesPerFrame, last)
+	}
+	for line := 1; line <= p.LinesPerFrame; line++ {
+		// A picture line may end in the first field sync pulse at mid-line,
+		// but must start with normal line sync.
+		if p.videoLine(line) >= 0 && p.halfLineSync(line, 0) != syncNormal {
+			return fmt.Errorf("active line %d overlaps the field sync pulses", line)
+		}
+	}
+
+	lineDuration := 1.0 / (p.FrameRate * float64(p.LinesPerFrame))
+	if p.HSync <= 0 {
+		return fmt.Errorf("line sync width must be positive")
+	}
+	if p.EqPulse <= 0 || p.EqPulse >= p.HSync {
+		return fmt.Errorf("equalising pulse (%.2f µs) must be positive and shorter than line sync (%.2f µs)",
+			p.EqPulse/1e-6, p.HSync/1e-6)
+	}
+	if p.BroadPulse <= p.HSync || p.BroadPulse >= lineDuration/2 {
+		return fmt.Errorf("broad pulse (%.2f µs) must be longer than line sync and shorter than half a line (%.2f µs)",
+			p.BroadPulse/1e-6, lineDuration/2/1e-6)
+	}
+	if p.ActiveLength <= 0 {
+		return fmt.Errorf("active line length must be positive")
+	}
+	if p.ActiveStart < p.HSync {
+		return fmt.Errorf("active video (%.2f µs) starts inside line sync (%.2f µs)",
+			p.ActiveStart/1e-6, p.HSync/1e-6)
+	}
+	if end := p.ActiveStart + p.ActiveLength; end > lineDuration {
+		return fmt.Errorf("active video ends at %.2f µs, past the end of the %.2f µs line",
+			end/1e-6, lineDuration/1e-6)
+	}
+
+	if p.Bandwidth <= 0 {
+		return fmt.Errorf("video bandwidth must be positive")
+	}
+	if p.Vestige <= 0 || p.Vestige >= p.Bandwidth {
+		return fmt.Errorf("vestigial sideband (%.2f MHz) must be positive and narrower than the video bandwidth (%.2f MHz)",
+			p.Vestige/1e6, p.Bandwidth/1e6)
+	}
+
+	if p.Colour == ColourNTSC || p.Colour == ColourPAL {
+		if p.Fsc <= 0 {
+			return fmt.Errorf("colour subcarrier frequency must be positive")
+		}
+		if p.BurstLength <= 0 || p.BurstAmplitude <= 0 {
+			return fmt.Errorf("colour burst needs a positive length and amplitude")
+		}
+		if p.BurstStart < p.HSync || p.BurstStart+p.BurstLength > p.ActiveStart {
+			return fmt.Errorf("colour burst (%.2f-%.2f µs) must sit on the back porch between line sync and active video",
+				p.BurstStart/1e-6, (p.BurstStart+p.BurstLength)/1e-6)
+		}
+	}
+
+	if p.Colour == ColourSECAM && (p.BurstStart < p.HSync || p.BurstStart > p.ActiveStart) {
+		return fmt.Errorf("SECAM subcarrier start (%.2f µs) must sit on the back porch", p.BurstStart/1e-6)
+	}
+
+	if p.ResidualCarrier < 0 || p.ResidualCarrier >= 1 {
+		return fmt.Errorf("residual carrier must be from 0 up to but not including 1 (all of peak carrier), got %g", p.ResidualCarrier)
+	}
+
+	if !(p.LevelSync < p.LevelBlanking && p.LevelBlanking <= p.LevelBlack && p.LevelBlack < p.LevelWhite) {
+		return fmt.Errorf("levels must satisfy sync < blanking <= black < white, got %g, %g, %g, %g",
+			p.LevelSync, p.LevelBlanking, p.LevelBlack, p.LevelWhite)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package video
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadParamsShipped(t *testing.T) {
+	tests := []struct {
+		file     string
+		lines    int
+		colour   ColourSystem
+		polarity Polarity
+	}{
+		{"narrow-313.json", 313, ColourNone, NegativeModulation},
+		{"system-a-405.json", 405, ColourNone, PositiveModulation},
+	}
+	for _, tt := range tests {
+		p, err := LoadParams(filepath.Join("..", "standards", tt.file))
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if p.LinesPerFrame != tt.lines || p.Colour != tt.colour || p.Polarity != tt.polarity {
+			t.Errorf("%s: got %d lines, colour %v, %v; want %d, %v, %v",
+				tt.file, p.LinesPerFrame, p.Colour, p.Polarity, tt.lines, tt.colour, tt.polarity)
+		}
+	}
+}
+
+// validDefinition is a small self-consistent standard that each rejection
+// case breaks in one way.
+const validDefinition = `{
+  "colour": "none",
+  "frame_rate": 25,
+  "lines": 313,
+  "active_lines": 280,
+  "first_active_line": 14,
+  "field_sync_line": 1,
+  "equalising_pulses": 2,
+  "broad_pulses": 4,
+  "bandwidth_hz": 2000000,
+  "timing_us": {"hsync": 8, "broad_pulse": 54, "eq_pulse": 4, "active_start": 16, "active_length": 108},
+  "levels": {"sync": -40, "blanking": 0, "black": 0, "white": 100}
+}`
+
+// writeDefinition writes a definition to a temporary file, with fields set
+// or replaced by edit.
+func writeDefinition(t *testing.T, edit map[string]any) string {
+	t.Helper()
+	var def map[string]any
+	if err := json.Unmarshal([]byte(validDefinition), &def); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range edit {
+		def[k] = v
+	}
+	data, err := json.Marshal(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, string(data))
+}
+
+func writeFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "standard.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadParamsValid(t *testing.T) {
+	if _, err := LoadParams(writeDefinition(t, nil)); err != nil {
+		t.Fatalf("valid definition rejected: %v", err)
+	}
+}
+
+func TestLoadParamsRejects(t *testing.T) {
+	timing := func(activeStart float64) map[string]any {
+		return map[string]any{"hsync": 8, "broad_pulse": 54, "eq_pulse": 4, "active_start": activeStart, "active_length": 108}
+	}
+	tests := []struct {
+		name string
+		edit map[string]any
+		want string
+	}{
+		{"unknown field", map[string]any{"frame_rat": 25}, "unknown field"},
+		{"zero frame rate", map[string]any{"frame_rate": 0}, "frame rate must be positive"},
+		{"negative frame rate", map[string]any{"frame_rate": -25}, "frame rate must be positive"},
+		{"zero bandwidth", map[string]any{"bandwidth_hz": 0}, "bandwidth must be positive"},
+		{"negative bandwidth", map[string]any{"bandwidth_hz": -2e6}, "bandwidth must be positive"},
+		{"picture over field sync", map[string]any{"first_active_line": 2}, "overlaps the field sync pulses"},
+		{"picture inside line sync", map[string]any{"timing_us": timing(4)}, "starts inside line sync"},
+		{"bad field order", map[string]any{"field_order": "middle"}, "unknown field order"},
+		{"bad colour", map[string]any{"colour": "mauve"}, "unknown colour system"},
+	}
+	for _, tt := range tests {
+		_, err := LoadParams(writeDefinition(t, tt.edit))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := LoadParams(writeFile(t, `{"lines": 313,`)); err == nil || !strings.Contains(err.Error(), "failed to parse") {
+		t.Errorf("bad JSON: got error %v, want a parse error", err)
+	}
+}
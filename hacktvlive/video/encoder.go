@@ -0,0 +1,322 @@
+package video
+
+import (
+	"runtime"
+	"sync"
+)
+
+// encoder holds the line timing, levels and buffers shared by every colour
+// system. NTSC, PAL and SECAM embed it and add their own chroma.
+type encoder struct {
+	params          Params
+	sampleRate      float64
+	samplesPerLine  float64
+	samplesPerFrame float64
+	framePeriod     uint64
+	colourFrames    uint64
+	lines           []lineTiming
+	templates       map[templateKey][]float64
+	rawFrameBuffer  []byte
+	rawFrameMutex   sync.RWMutex
+	picture         []byte
+	frameStore      []float64
+	frameBuffer     []float64
+	frameMutex      sync.RWMutex
+	inserters       map[int]LineInserter
+	frame           uint64
+	nextFrame       uint64
+	nextField       int
+	burnIn          bool
+	fieldMotion     bool
+	lumaTaps        []float64
+	resampler       *resampler
+	scratch         []*scratch
+}
+
+// scratch holds the buffers one goroutine needs to render a line, so lines
+// can be rendered in parallel without sharing any.
+type scratch struct {
+	rgb      []float64 // the active line's picture, three values per sample
+	rgbStart int       // sample of the line rgb starts on
+	rgbValid bool      // whether the line has a raster row under it
+	filtered []float64 // output of the filter last run
+
+	// The two colour-difference signals of the line, raw and filtered.
+	a, b       []float64
+	aOut, bOut []float64
+}
+
+// chromaEncoder is implemented by each colour system to add chroma to a line
+// that already holds sync and luma. It may be called for several lines at
+// once from different goroutines, each with its own scratch.
+type chromaEncoder interface {
+	addChroma(line int, lineBuffer []float64, sc *scratch)
+}
+
+// init sets up the line timing and buffers for the standard's parameter table.
+func (e *encoder) init(p Params, sampleRate float64) {
+	e.params = p
+	e.sampleRate = sampleRate
+	e.rawFrameBuffer = make([]byte, p.RasterWidth*p.RasterHeight*3)
+	e.picture = make([]byte, len(e.rawFrameBuffer))
+	e.SetInterpolation(DefaultInterpolation)
+	e.SetWorkers(0)
+	e.initTiming()
+	e.layoutFrame()
+}
+
+// generateFrame renders both fields of a frame, leaving the whole frame in
+// the frame buffer. A frame whose first field was generated on its own is
+// abandoned.
+func (e *encoder) generateFrame(c chromaEncoder) {
+	e.nextField = 0
+	e.generateField(c)
+	e.generateField(c)
+	last := e.lines[len(e.lines)-1]
+	e.frameBuffer = e.frameStore[:last.offset+last.samples]
+}
+
+// generateField renders every line of the next field straight into the frame
+// store, letting the colour system add its chroma on top of the shared sync
+// and luma, then runs the VBI inserters registered on each line. The frame
+// buffer is left holding just that field. The raw frame is copied under a
+// single lock once a frame, or once a field when the source runs at the field
+// rate so that every field gets its own picture.
+func (e *encoder) generateField(c chromaEncoder) {
+	if e.nextField == 0 {
+		e.frame = e.nextFrame
+		e.nextFrame++
+		e.layoutFrame()
+	}
+
+	if e.nextField == 0 || e.SourceRate() > e.params.FrameRate {
+		e.rawFrameMutex.RLock()
+		copy(e.picture, e.rawFrameBuffer)
+		e.rawFrameMutex.RUnlock()
+		if e.burnIn {
+			e.drawTimecode()
+		}
+	}
+
+	// Field 1 is lines 1 to (LinesPerFrame+1)/2 and field 2 the rest, as
+	// Params.field numbers them: the split falls after line 263 on 525-line
+	// systems, whose field 2 equalising pulses start half-way through it, and
+	// after line 313 on 625-line systems, part way through field 2's sync,
+	// which starts with equalising pulses on line 311 and broad pulses half-way
+	// through 313.
+	first, last := 1, (e.params.LinesPerFrame+1)/2
+	if e.nextField == 1 {
+		first, last = last+1, e.params.LinesPerFrame
+	}
+	e.renderLines(c, first, last)
+	// Inserters keep state from line to line, so they run in line order
+	// once the lines are done.
+	for line := first; line <= last; line++ {
+		lt := e.timing(line)
+		e.runInserter(line, e.frameStore[lt.offset:lt.offset+lt.samples])
+	}
+
+	end := e.lines[last-1]
+	e.frameBuffer = e.frameStore[e.lines[first-1].offset : end.offset+end.samples]
+	e.nextField ^= 1
+}
+
+// renderLines renders lines first to last, split into one band of
+// consecutive lines per worker. Every line depends only on the frame, its
+// line number and the picture, so the output is the same however the lines
+// are shared out.
+func (e *encoder) renderLines(c chromaEncoder, first, last int) {
+	workers := len(e.scratch)
+	band := (last - first + workers) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from, to := first+w*band, min(first+(w+1)*band-1, last)
+		if from > to {
+			break
+		}
+		render := func(sc *scratch) {
+			for line := from; line <= to; line++ {
+				if e.isPicture(line) {
+					e.resampleLine(line, sc)
+				}
+				c.addChroma(line, e.generateLumaLine(line, sc), sc)
+			}
+		}
+		if w == workers-1 || to == last {
+			render(e.scratch[w])
+			break
+		}
+		wg.Add(1)
+		go func(sc *scratch) {
+			defer wg.Done()
+			render(sc)
+		}(e.scratch[w])
+	}
+	wg.Wait()
+}
+
+// SetWorkers sets how many lines are rendered at once. Zero or less uses one
+// worker per CPU.
+func (e *encoder) SetWorkers(n int) {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	e.scratch = make([]*scratch, n)
+	for i := range e.scratch {
+		e.scratch[i] = &scratch{}
+	}
+}
+
+// timing returns where a line of the current frame sits on the sample grid.
+func (e *encoder) timing(line int) lineTiming {
+	return e.lines[line-1]
+}
+
+// syncPulse returns the width in seconds of the pulse that starts half of a
+// line: line sync, an equalising or a broad pulse at the start of the line,
+// and nothing or a field sync pulse at mid-line.
+func (e *encoder) syncPulse(line, half int) float64 {
+	switch e.params.halfLineSync(line, half) {
+	case syncEqualising:
+		return e.params.EqPulse
+	case syncBroad:
+		return e.params.BroadPulse
+	}
+	if half == 0 {
+		return e.params.HSync
+	}
+	return 0
+}
+
+// isPicture reports whether a line carries active video. The last picture
+// line of a field may stop at mid-line for the first equalising pulse.
+func (e *encoder) isPicture(line int) bool {
+	t := e.params.LineType(line)
+	return t == LineActive || t == LineHalfActive
+}
+
+// SetInterpolation chooses how raster rows are resampled onto active lines.
+func (e *encoder) SetInterpolation(kind Interpolation) {
+	e.resampler = newResampler(kind, e.params.RasterWidth, e.params.ActiveLength, e.sampleRate)
+}
+
+// resampleLine resamples the raster row under an active line onto the
+// line's samples in sc, from the copy of the raw frame taken for the field
+// being generated.
+func (e *encoder) resampleLine(line int, sc *scratch) {
+	lt := e.timing(line)
+	videoLine := e.params.videoLine(line)
+	sc.rgbValid = videoLine >= 0 && videoLine < e.params.RasterHeight
+	if !sc.rgbValid {
+		return
+	}
+
+	width := e.params.RasterWidth
+	sc.rgb = grow(sc.rgb, (lt.activeEnd-lt.activeStart)*3)
+	sc.rgbStart = lt.activeStart
+	row := e.picture[videoLine*width*3 : (videoLine+1)*width*3]
+	t0 := (float64(lt.activeStart) - lt.origin) / e.sampleRate
+	x0 := (t0-e.params.ActiveStart)/e.params.ActiveLength*float64(width) - 0.5
+	e.resampler.resampleRow(sc.rgb, row, x0)
+}
+
+// getPixelRGB returns the picture at a sample of the active line last
+// resampled into sc.
+func (e *encoder) getPixelRGB(sc *scratch, sampleInLine int) (r, g, b float64, ok bool) {
+	k := sampleInLine - sc.rgbStart
+	if !sc.rgbValid || k < 0 || k*3 >= len(sc.rgb) {
+		return 0, 0, 0, false
+	}
+	return sc.rgb[k*3], sc.rgb[k*3+1], sc.rgb[k*3+2], true
+}
+
+// getPixelY returns the luma level in IRE for a sample of an active line.
+func (e *encoder) getPixelY(sc *scratch, sampleInLine int) float64 {
+	r, g, b, ok := e.getPixelRGB(sc, sampleInLine)
+	if !ok {
+		return e.params.LevelBlack
+	}
+	yVal := 0.299*r + 0.587*g + 0.114*b
+	return e.params.LevelBlack + yVal/255.0*(e.params.LevelWhite-e.params.LevelBlack)
+}
+
+// generateLumaLine lays a line's sync template into its place in the frame
+// store and fills in the luma of any picture, returning the line's slice of
+// the store.
+func (e *encoder) generateLumaLine(currentLine int, sc *scratch) []float64 {
+	lt := e.timing(currentLine)
+	lineBuffer := e.frameStore[lt.offset : lt.offset+lt.samples]
+	copy(lineBuffer, lt.sync)
+
+	if e.isPicture(currentLine) {
+		for s := lt.activeStart; s < lt.activeEnd; s++ {
+			lineBuffer[s] = e.getPixelY(sc, s)
+		}
+		filterActive(lineBuffer, lt, e.lumaTaps, sc)
+	}
+	return lineBuffer
+}
+
+// grow returns buf resized to n, reallocating only when it is too small, so
+// scratch buffers are allocated once.
+func grow(buf []float64, n int) []float64 {
+	if cap(buf) < n {
+		return make([]float64, n)
+	}
+	return buf[:n]
+}
+
+// IreToAmplitude maps a level to AM carrier amplitude, 1 being peak carrier.
+// Sync tip and white sit at peak and residual carrier, one way round or the
+// other depending on the polarity of modulation.
+func (e *encoder) IreToAmplitude(ire float64) float64 {
+	p := e.params
+	x := (ire - p.LevelSync) / (p.LevelWhite - p.LevelSync)
+	if p.Polarity == NegativeModulation {
+		x = 1 - x
+	}
+	return p.ResidualCarrier + x*(1-p.ResidualCarrier)
+}
+
+func (e *encoder) FillTestPattern() {
+	FillColorBars(e.rawFrameBuffer, e.params.RasterWidth, e.params.RasterHeight)
+}
+
+// RasterSize returns the width and height of the raw RGB frame.
+func (e *encoder) RasterSize() (width, height int) {
+	return e.params.RasterWidth, e.params.RasterHeight
+}
+
+// SourceRate returns how many times a second the raw frame should be
+// refreshed: once a field on progressive standards, where each field is a
+// whole picture, or with field-rate motion, and once a frame otherwise.
+func (e *encoder) SourceRate() float64 {
+	if e.params.Progressive || e.fieldMotion {
+		return 2 * e.params.FrameRate
+	}
+	return e.params.FrameRate
+}
+
+// SetFieldRateMotion says whether the source supplies a new picture for
+// every field rather than every frame. Each field of an interlaced frame
+// then shows its own moment in time, for true 60i/50i motion.
+func (e *encoder) SetFieldRateMotion(on bool) {
+	e.fieldMotion = on
+}
+
+// FrameNumber returns the sequence number of the frame in, or holding the
+// field in, the frame buffer, counting from 0. The caller should hold the
+// frame lock.
+func (e *encoder) FrameNumber() uint64 { return e.frame }
+
+func (e *encoder) Params() Params         { return e.params }
+func (e *encoder) FrameRate() float64     { return e.params.FrameRate }
+func (e *encoder) SampleRate() float64    { return e.sampleRate }
+func (e *encoder) LockFrame()             { e.frameMutex.Lock() }
+func (e *encoder) UnlockFrame()           { e.frameMutex.Unlock() }
+func (e *encoder) RLockFrame()            { e.frameMutex.RLock() }
+func (e *encoder) RUnlockFrame()          { e.frameMutex.RUnlock() }
+func (e *encoder) LockRaw()               { e.rawFrameMutex.Lock() }
+func (e *encoder) UnlockRaw()             { e.rawFrameMutex.Unlock() }
+func (e *encoder) FrameBuffer() []float64 { return e.frameBuffer }
+func (e *encoder) RawFrameBuffer() []byte { return e.rawFrameBuffer }
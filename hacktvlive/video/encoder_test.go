@@ -0,0 +1,102 @@
+package video
+
+import (
+	"math"
+	"testing"
+)
+
+// benchRate is the HackRF's fixed sample rate, at which fields are rendered.
+const benchRate = 8e6
+
+// BenchmarkGenerateField renders fields of the colour bars with and without
+// the chroma filters that -chroma-filter turns on by default.
+func BenchmarkGenerateField(b *testing.B) {
+	for _, name := range []string{"ntsc", "pal", "secam"} {
+		for _, filtered := range []bool{false, true} {
+			sub := name + "/plain"
+			if filtered {
+				sub = name + "/chroma-filter"
+			}
+			b.Run(sub, func(b *testing.B) {
+				v := New(Presets[name], benchRate)
+				v.SetFilters(FilterOptions{Chroma: filtered})
+				v.FillTestPattern()
+				// The first frame lays out the line timing.
+				v.GenerateField()
+				v.GenerateField()
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					v.GenerateField()
+				}
+			})
+		}
+	}
+}
+
+// renderFields builds a standard with every inserter and filter on, renders
+// fields of the colour bars across the given number of workers, and returns
+// the samples of each field.
+func renderFields(t *testing.T, name string, workers, fields int) [][]float64 {
+	t.Helper()
+	p := Presets[name]
+	v := New(p, benchRate)
+	v.SetWorkers(workers)
+	v.SetFilters(FilterOptions{Chroma: true, Luma: true, LumaNotch: true})
+	v.SetInterpolation(InterpLanczos)
+	v.SetTimecodeBurnIn(true)
+	v.FillTestPattern()
+
+	vits := TestLinePresets["its"]
+	if p.LinesPerFrame == 525 {
+		vits = TestLinePresets["ntc7"]
+	}
+	for line, sig := range vits {
+		if err := v.AddInserter(sig, line); err != nil {
+			t.Fatalf("%s: adding test signal: %v", name, err)
+		}
+	}
+	var err error
+	if p.LinesPerFrame == 525 {
+		err = v.AddInserter(&CGMSInserter{Widescreen: true}, CGMSLines(p)...)
+	} else {
+		err = v.AddInserter(&WSSInserter{Widescreen: true}, WSSLine(p))
+	}
+	if err != nil {
+		t.Fatalf("%s: adding aspect ratio signalling: %v", name, err)
+	}
+	if err := v.AddInserter(VITCInserter{}, VITCLines(p, DefaultVITCLines(p))...); err != nil {
+		t.Fatalf("%s: adding VITC: %v", name, err)
+	}
+	if err := v.AddInserter(NewTeletextInserter(nullTeletext{}), v.FreeLines(DefaultTeletextLines)...); err != nil {
+		t.Fatalf("%s: adding teletext: %v", name, err)
+	}
+
+	out := make([][]float64, fields)
+	for i := range out {
+		v.GenerateField()
+		out[i] = append([]float64(nil), v.FrameBuffer()...)
+	}
+	return out
+}
+
+// TestWorkersBitIdentical renders the same fields on one worker and on
+// several, which must give exactly the same samples.
+func TestWorkersBitIdentical(t *testing.T) {
+	const fields = 4
+	for _, name := range []string{"ntsc", "pal", "secam"} {
+		one := renderFields(t, name, 1, fields)
+		many := renderFields(t, name, 5, fields)
+		for f := range one {
+			if len(one[f]) != len(many[f]) {
+				t.Fatalf("%s field %d: %d samples on one worker, %d on five", name, f, len(one[f]), len(many[f]))
+			}
+			for i := range one[f] {
+				if math.Float64bits(one[f][i]) != math.Float64bits(many[f][i]) {
+					t.Errorf("%s field %d: sample %d is %v on one worker, %v on five", name, f, i, one[f][i], many[f][i])
+					break
+				}
+			}
+		}
+	}
+}
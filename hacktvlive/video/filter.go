@@ -0,0 +1,95 @@
+package video
+
+import (
+	"math"
+
+	"hacktvlive/dsp"
+)
+
+// FilterOptions selects the band-limiting applied to the picture. Each
+// filter can be switched off to compare spectra.
+type FilterOptions struct {
+	// Chroma low-passes the colour-difference signals before modulation:
+	// I at 1.3 MHz and Q at 0.4 MHz on NTSC, U and V at 1.3 MHz on PAL.
+	Chroma bool
+	// Luma low-passes luma at the standard's video bandwidth.
+	Luma bool
+	// LumaNotch takes the colour subcarrier band out of luma, trading
+	// detail for less cross-colour.
+	LumaNotch bool
+}
+
+// DefaultFilters band-limits chroma only, as a studio encoder would.
+var DefaultFilters = FilterOptions{Chroma: true}
+
+// Chroma bandwidths of the colour-difference signals, and the half-width of
+// the luma notch around the subcarrier.
+const (
+	ntscIBandwidth = 1.3e6
+	ntscQBandwidth = 0.4e6
+	palUVBandwidth = 1.3e6
+	notchHalfWidth = 0.6e6
+)
+
+// lowPassTaps designs a low-pass filter for a cutoff frequency, four sample
+// periods of the cutoff long. The response scales with the cutoff at any
+// sample rate: 1 dB down at 0.72 of it, 3 dB at 0.87 and 6 dB at the cutoff
+// itself, then about -40 dB at 1.5 times and below -70 dB from 1.7 times the
+// cutoff. The transition band is nearly as wide as the cutoff, which keeps
+// the filter short. A cutoff at or above Nyquist needs no filter and gives
+// nil.
+func lowPassTaps(cutoff, sampleRate float64) []float64 {
+	if cutoff >= sampleRate/2 {
+		return nil
+	}
+	numTaps := 2*int(math.Ceil(2*sampleRate/cutoff)) + 1
+	return dsp.NewLowPassFilterTaps(numTaps, 2*cutoff, sampleRate)
+}
+
+// notchTaps designs a band-stop filter around a frequency: an all-pass less
+// the band-pass between two low-passes, each long enough for edges much
+// sharper than the notch is wide. The frequency is folded into the sampled
+// band first, which is where a subcarrier above Nyquist ends up.
+func notchTaps(freq, halfWidth, sampleRate float64) []float64 {
+	freq = math.Mod(freq, sampleRate)
+	if freq > sampleRate/2 {
+		freq = sampleRate - freq
+	}
+	numTaps := 2*int(math.Ceil(2*sampleRate/halfWidth)) + 1
+	taps := dsp.NewLowPassFilterTaps(numTaps, 2*math.Max(freq-halfWidth, halfWidth/2), sampleRate)
+	// With its upper edge past Nyquist the band-pass is everything above
+	// the lower edge, and the notch is just the low-pass.
+	if freq+halfWidth < sampleRate/2 {
+		taps[numTaps/2] += 1
+		for i, tap := range dsp.NewLowPassFilterTaps(numTaps, 2*(freq+halfWidth), sampleRate) {
+			taps[i] -= tap
+		}
+	}
+	return taps
+}
+
+// SetFilters chooses the band-limiting applied to the picture from the next
+// field on.
+func (e *encoder) SetFilters(f FilterOptions) {
+	e.lumaTaps = nil
+	if f.Luma {
+		e.lumaTaps = lowPassTaps(e.params.Bandwidth, e.sampleRate)
+	}
+	if f.LumaNotch && e.params.Colour != ColourNone {
+		notch := notchTaps(e.params.Fsc, notchHalfWidth, e.sampleRate)
+		if e.lumaTaps != nil {
+			notch = dsp.Convolve(e.lumaTaps, notch)
+		}
+		e.lumaTaps = notch
+	}
+}
+
+// filterActive applies a filter to the active part of a line in place.
+func filterActive(lineBuffer []float64, lt lineTiming, taps []float64, sc *scratch) {
+	if taps == nil {
+		return
+	}
+	active := lineBuffer[lt.activeStart:lt.activeEnd]
+	sc.filtered = dsp.Filter(sc.filtered, active, taps)
+	copy(active, sc.filtered)
+}
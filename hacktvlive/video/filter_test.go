@@ -0,0 +1,44 @@
+package video
+
+import (
+	"math"
+	"testing"
+)
+
+// gainDB is a filter's gain in dB at a frequency.
+func gainDB(taps []float64, freq, sampleRate float64) float64 {
+	var re, im float64
+	for i, tap := range taps {
+		phase := 2 * math.Pi * freq / sampleRate * float64(i)
+		re += tap * math.Cos(phase)
+		im -= tap * math.Sin(phase)
+	}
+	return 20 * math.Log10(math.Hypot(re, im))
+}
+
+// TestLowPassResponse holds lowPassTaps to the response its comment gives,
+// for the chroma cutoffs at both ends of the sample rates in use.
+func TestLowPassResponse(t *testing.T) {
+	tests := []struct {
+		ratio    float64 // frequency as a fraction of the cutoff
+		min, max float64 // gain bounds, dB
+	}{
+		{0.5, -0.2, 0.1},
+		{0.72, -1.2, -0.8},
+		{0.87, -3.3, -2.7},
+		{1, -6.3, -5.7},
+		{1.5, -45, -35},
+		{1.7, -200, -70},
+	}
+	for _, rate := range []float64{8e6, RenderRate} {
+		for _, cutoff := range []float64{ntscQBandwidth, palUVBandwidth} {
+			taps := lowPassTaps(cutoff, rate)
+			for _, tt := range tests {
+				if g := gainDB(taps, tt.ratio*cutoff, rate); g < tt.min || g > tt.max {
+					t.Errorf("%.1f MHz cutoff at %.1f Msps: %.1f dB at %.2f of the cutoff, want %g to %g dB",
+						cutoff/1e6, rate/1e6, g, tt.ratio, tt.min, tt.max)
+				}
+			}
+		}
+	}
+}
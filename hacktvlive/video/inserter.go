@@ -0,0 +1,134 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// LineInfo tells an inserter which line it is writing and how the line is
+// laid out in samples. Sample 0 is the first sample at or before the leading
+// edge of line sync.
+type LineInfo struct {
+	Frame  uint64 // frame sequence number, counting from 0
+	Field  int    // 1 or 2
+	Line   int    // line number in the frame, from 1
+	Params Params
+
+	SampleRate         float64
+	LineSamples        int
+	ActiveStartSamples int
+	ActiveSamples      int
+
+	origin          float64
+	subcarrierStart float64
+}
+
+// Time returns the time of a sample in seconds from the leading edge of sync.
+func (li LineInfo) Time(sample int) float64 {
+	return (float64(sample) - li.origin) / li.SampleRate
+}
+
+// Sample returns the fractional sample position within the line of a time
+// in seconds from the leading edge of sync. The exact start of a line falls
+// between samples, so this is how inserters should place their waveforms.
+func (li LineInfo) Sample(t float64) float64 {
+	return li.origin + t*li.SampleRate
+}
+
+// SubcarrierPhase returns the phase of the colour subcarrier at a sample,
+// continuous with the chroma and burst the standard generates. Adding pi
+// gives burst phase on NTSC and the mean burst phase on PAL.
+func (li LineInfo) SubcarrierPhase(sample int) float64 {
+	return li.subcarrierStart + 2.0*math.Pi*li.Params.Fsc*float64(sample)/li.SampleRate
+}
+
+// LineInserter writes a waveform into a VBI line. The line buffer holds the
+// line's sync, blanking and burst as levels in IRE; the inserter adds its
+// signal to it or overwrites part of it.
+type LineInserter interface {
+	InsertLine(info LineInfo, lineBuffer []float64)
+}
+
+// InserterFunc adapts an ordinary function to a LineInserter.
+type InserterFunc func(info LineInfo, lineBuffer []float64)
+
+func (f InserterFunc) InsertLine(info LineInfo, lineBuffer []float64) { f(info, lineBuffer) }
+
+// AddInserter registers ins to run on each of the given lines every frame.
+// Lines must be in the vertical blanking interval, outside field sync, and
+// not already claimed by another inserter, and none may be given twice.
+func (e *encoder) AddInserter(ins LineInserter, lines ...int) error {
+	for i, line := range lines {
+		if err := e.checkInserterLine(line); err != nil {
+			return err
+		}
+		if slices.Contains(lines[:i], line) {
+			return fmt.Errorf("line %d is given twice", line)
+		}
+	}
+	if e.inserters == nil {
+		e.inserters = make(map[int]LineInserter)
+	}
+	for _, line := range lines {
+		e.inserters[line] = ins
+	}
+	return nil
+}
+
+// FreeLines returns those of the given lines an inserter could still be
+// added to, in order, for a service that takes whatever the others have left.
+func (e *encoder) FreeLines(lines []int) []int {
+	var free []int
+	for _, line := range lines {
+		if e.checkInserterLine(line) == nil {
+			free = append(free, line)
+		}
+	}
+	return free
+}
+
+// checkInserterLine says why an inserter cannot go on a line, if it cannot.
+func (e *encoder) checkInserterLine(line int) error {
+	if line < 1 || line > e.params.LinesPerFrame {
+		return fmt.Errorf("line %d is outside the %d-line frame", line, e.params.LinesPerFrame)
+	}
+	if e.isPicture(line) {
+		return fmt.Errorf("line %d carries picture", line)
+	}
+	if e.params.inFieldSync(line) {
+		return fmt.Errorf("line %d is part of field sync", line)
+	}
+	if _, taken := e.inserters[line]; taken {
+		return fmt.Errorf("line %d already has an inserter", line)
+	}
+	return nil
+}
+
+// runInserter calls the inserter registered on a line, if there is one.
+func (e *encoder) runInserter(line int, lineBuffer []float64) {
+	ins, ok := e.inserters[line]
+	if !ok {
+		return
+	}
+	lt := e.timing(line)
+	ins.InsertLine(LineInfo{
+		Frame:              e.frame,
+		Field:              e.params.field(line),
+		Line:               line,
+		Params:             e.params,
+		SampleRate:         e.sampleRate,
+		LineSamples:        lt.samples,
+		ActiveStartSamples: lt.activeStart,
+		ActiveSamples:      lt.activeEnd - lt.activeStart,
+		origin:             lt.origin,
+		subcarrierStart:    e.subcarrierPhase(line, 0),
+	}, lineBuffer)
+}
+
+// subcarrierPhase is the phase of the colour subcarrier at a sample of a line.
+// Chroma, burst and inserters all take their phase from here, so it is
+// continuous across lines and frames.
+func (e *encoder) subcarrierPhase(line, sampleInLine int) float64 {
+	return e.timing(line).phase + 2.0*math.Pi*e.params.Fsc*float64(sampleInLine)/e.sampleRate
+}
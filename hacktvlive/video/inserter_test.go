@@ -0,0 +1,51 @@
+package video
+
+import (
+	"slices"
+	"testing"
+)
+
+// nullTeletext is a teletext source with nothing to send.
+type nullTeletext struct{}
+
+func (nullTeletext) NextPacket() []byte { return nil }
+
+// TestTeletextDefaultsAvoidVITC follows -standard pal -vitc -widescreen
+// -teletext: teletext takes whichever of its default lines VITC and WSS have
+// left.
+func TestTeletextDefaultsAvoidVITC(t *testing.T) {
+	p := Presets["pal"]
+	v := New(p, RenderRate)
+	vitc := VITCLines(p, DefaultVITCLines(p))
+	if err := v.AddInserter(VITCInserter{}, vitc...); err != nil {
+		t.Fatalf("adding VITC: %v", err)
+	}
+	if err := v.AddInserter(&WSSInserter{Widescreen: true}, WSSLine(p)); err != nil {
+		t.Fatalf("adding WSS: %v", err)
+	}
+
+	lines := v.FreeLines(DefaultTeletextLines)
+	for _, line := range vitc {
+		if slices.Contains(lines, line) {
+			t.Errorf("teletext offered VITC line %d", line)
+		}
+	}
+	if want := len(DefaultTeletextLines) - len(vitc); len(lines) != want {
+		t.Errorf("teletext got %d lines %v, want %d", len(lines), lines, want)
+	}
+	if err := v.AddInserter(NewTeletextInserter(nullTeletext{}), lines...); err != nil {
+		t.Fatalf("adding teletext: %v", err)
+	}
+}
+
+func TestAddInserterRejectsRepeatedLine(t *testing.T) {
+	p := Presets["pal"]
+	v := New(p, RenderRate)
+	line := DefaultVITCLines(p)[0]
+	if err := v.AddInserter(VITCInserter{}, line, line); err == nil {
+		t.Fatalf("line %d given twice was accepted", line)
+	}
+	if err := v.AddInserter(VITCInserter{}, line); err != nil {
+		t.Fatalf("a rejected call left line %d claimed: %v", line, err)
+	}
+}
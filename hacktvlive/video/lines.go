@@ -0,0 +1,60 @@
+package video
+
+// LineType classifies a line of the frame by what it carries.
+type LineType int
+
+const (
+	// LineEqualising has equalising pulses and no broad pulse.
+	LineEqualising LineType = iota
+	// LineBroad has at least one broad pulse.
+	LineBroad
+	// LineVBI has normal line sync and no picture; VBI data can go here.
+	LineVBI
+	// LineActive carries picture for the whole active line.
+	LineActive
+	// LineHalfActive carries picture in its first half only, ending at the
+	// first equalising pulse of the next field: NTSC line 263 and PAL line
+	// 623. The half-line at the top of the picture, NTSC line 284 and PAL
+	// line 23, is left as LineVBI: the raster has no row for it, so its
+	// second half would only ever be black, and its first half is where
+	// services such as WSS on line 23 go.
+	LineHalfActive
+)
+
+var lineTypeNames = [...]string{"equalising", "broad", "vbi", "active", "half-active"}
+
+func (t LineType) String() string {
+	if t < 0 || int(t) >= len(lineTypeNames) {
+		return "unknown"
+	}
+	return lineTypeNames[t]
+}
+
+// LineType classifies a line from its sync pulses and the picture layout.
+func (p Params) LineType(line int) LineType {
+	first, second := p.halfLineSync(line, 0), p.halfLineSync(line, 1)
+	switch {
+	case first == syncBroad || second == syncBroad:
+		return LineBroad
+	case first == syncEqualising:
+		return LineEqualising
+	case p.videoLine(line) < 0:
+		if second == syncEqualising {
+			return LineEqualising
+		}
+		return LineVBI
+	case second == syncEqualising:
+		return LineHalfActive
+	}
+	return LineActive
+}
+
+// LineTypes returns the classification of every line of the frame; line n
+// is at index n-1.
+func (p Params) LineTypes() []LineType {
+	types := make([]LineType, p.LinesPerFrame)
+	for i := range types {
+		types[i] = p.LineType(i + 1)
+	}
+	return types
+}
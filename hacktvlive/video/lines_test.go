@@ -0,0 +1,120 @@
+package video
+
+import "testing"
+
+func progressive(t *testing.T, p Params) Params {
+	t.Helper()
+	prog, err := Progressive(p)
+	if err != nil {
+		t.Fatalf("Progressive(%s): %v", p.Name, err)
+	}
+	return prog
+}
+
+func TestLineType(t *testing.T) {
+	ntsc, pal := Presets["ntsc"], Presets["pal"]
+	ntsc240, pal288 := progressive(t, ntsc), progressive(t, pal)
+	palTFF, err := WithFieldOrder(pal, TopFieldFirst)
+	if err != nil {
+		t.Fatalf("PAL top field first: %v", err)
+	}
+
+	tests := []struct {
+		p           Params
+		first, last int
+		want        LineType
+	}{
+		{ntsc, 1, 3, LineEqualising},
+		{ntsc, 4, 6, LineBroad},
+		{ntsc, 7, 9, LineEqualising},
+		{ntsc, 10, 21, LineVBI},
+		{ntsc, 22, 262, LineActive},
+		{ntsc, 263, 263, LineHalfActive},
+		{ntsc, 264, 265, LineEqualising},
+		{ntsc, 266, 269, LineBroad},
+		{ntsc, 270, 272, LineEqualising},
+		{ntsc, 273, 284, LineVBI},
+		{ntsc, 285, 525, LineActive},
+
+		{pal, 1, 3, LineBroad},
+		{pal, 4, 5, LineEqualising},
+		{pal, 6, 23, LineVBI},
+		{pal, 24, 310, LineActive},
+		{pal, 311, 312, LineEqualising},
+		{pal, 313, 315, LineBroad},
+		{pal, 316, 318, LineEqualising},
+		{pal, 319, 335, LineVBI},
+		{pal, 336, 622, LineActive},
+		{pal, 623, 623, LineHalfActive},
+		{pal, 624, 625, LineEqualising},
+
+		{palTFF, 1, 3, LineBroad},
+		{palTFF, 4, 5, LineEqualising},
+		{palTFF, 6, 22, LineVBI},
+		{palTFF, 23, 310, LineActive},
+		{palTFF, 311, 312, LineEqualising},
+		{palTFF, 313, 315, LineBroad},
+		{palTFF, 316, 318, LineEqualising},
+		{palTFF, 319, 335, LineVBI},
+		{palTFF, 336, 622, LineActive},
+		{palTFF, 623, 625, LineEqualising},
+
+		{ntsc240, 1, 3, LineEqualising},
+		{ntsc240, 4, 6, LineBroad},
+		{ntsc240, 7, 9, LineEqualising},
+		{ntsc240, 10, 21, LineVBI},
+		{ntsc240, 22, 261, LineActive},
+		{ntsc240, 262, 262, LineVBI},
+		{ntsc240, 263, 265, LineEqualising},
+		{ntsc240, 266, 268, LineBroad},
+		{ntsc240, 269, 271, LineEqualising},
+		{ntsc240, 272, 283, LineVBI},
+		{ntsc240, 284, 523, LineActive},
+		{ntsc240, 524, 524, LineVBI},
+
+		{pal288, 1, 3, LineBroad},
+		{pal288, 4, 5, LineEqualising},
+		{pal288, 6, 22, LineVBI},
+		{pal288, 23, 309, LineActive},
+		{pal288, 310, 310, LineHalfActive},
+		{pal288, 311, 312, LineEqualising},
+		{pal288, 313, 315, LineBroad},
+		{pal288, 316, 317, LineEqualising},
+		{pal288, 318, 334, LineVBI},
+		{pal288, 335, 621, LineActive},
+		{pal288, 622, 622, LineHalfActive},
+		{pal288, 623, 624, LineEqualising},
+	}
+	for _, tt := range tests {
+		for line := tt.first; line <= tt.last; line++ {
+			if got := tt.p.LineType(line); got != tt.want {
+				t.Errorf("%s line %d: got %v, want %v", tt.p.Name, line, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestLineTypesCoversFrame(t *testing.T) {
+	for _, name := range []string{"ntsc", "pal"} {
+		p := Presets[name]
+		types := p.LineTypes()
+		if len(types) != p.LinesPerFrame {
+			t.Fatalf("%s: %d line types for %d lines", p.Name, len(types), p.LinesPerFrame)
+		}
+		for i, typ := range types {
+			if want := p.LineType(i + 1); typ != want {
+				t.Errorf("%s line %d: LineTypes gives %v, LineType %v", p.Name, i+1, typ, want)
+			}
+		}
+	}
+}
+
+func TestWithFieldOrderEveryPreset(t *testing.T) {
+	for name, p := range Presets {
+		for orderName, order := range FieldOrders {
+			if _, err := WithFieldOrder(p, order); err != nil {
+				t.Errorf("%s with %s: %v", name, orderName, err)
+			}
+		}
+	}
+}
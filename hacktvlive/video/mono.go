@@ -0,0 +1,25 @@
+package video
+
+// Monochrome sends luma only, with no colour subcarrier or burst.
+type Monochrome struct {
+	encoder
+}
+
+// NewMonochrome creates a new black-and-white standard object from a parameter table.
+func NewMonochrome(p Params, sampleRate float64) *Monochrome {
+	m := &Monochrome{}
+	m.init(p, sampleRate)
+	return m
+}
+
+// GenerateFullFrame creates a complete monochrome frame from the raw pixel data.
+func (m *Monochrome) GenerateFullFrame() {
+	m.generateFrame(m)
+}
+
+// GenerateField creates the next monochrome field on its own.
+func (m *Monochrome) GenerateField() {
+	m.generateField(m)
+}
+
+func (m *Monochrome) addChroma(line int, lineBuffer []float64, sc *scratch) {}
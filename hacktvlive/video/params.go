@@ -0,0 +1,370 @@
+package video
+
+import "fmt"
+
+// ColourSystem selects how chroma is encoded onto the luminance signal.
+type ColourSystem int
+
+const (
+	ColourNTSC ColourSystem = iota
+	ColourPAL
+	ColourSECAM
+	ColourNone
+)
+
+// FieldOrder says which field carries the top line of the picture. Field 1
+// is always sent first; on an interlaced standard field 2's lines fall half a
+// field line above or below field 1's depending on where each field's picture
+// starts.
+type FieldOrder int
+
+const (
+	// TopFieldFirst puts field 1 on the top line and the even raster rows.
+	TopFieldFirst FieldOrder = iota
+	// BottomFieldFirst puts field 2 on the top line and the even raster rows.
+	BottomFieldFirst
+)
+
+// FieldOrders maps the names accepted by -field-order to field orders.
+var FieldOrders = map[string]FieldOrder{
+	"tff": TopFieldFirst,
+	"bff": BottomFieldFirst,
+}
+
+func (o FieldOrder) String() string {
+	if o == BottomFieldFirst {
+		return "bottom field first"
+	}
+	return "top field first"
+}
+
+// Polarity says which way the picture modulates the vision carrier in AM.
+type Polarity int
+
+const (
+	// NegativeModulation puts sync tips at peak carrier and white lowest, as
+	// on nearly every system since the 1950s.
+	NegativeModulation Polarity = iota
+	// PositiveModulation puts white at peak carrier and sync lowest, as on
+	// French System L and British 405-line System A.
+	PositiveModulation
+)
+
+// Polarities maps the names used in standard definitions to polarities.
+var Polarities = map[string]Polarity{
+	"negative": NegativeModulation,
+	"positive": PositiveModulation,
+}
+
+func (m Polarity) String() string {
+	if m == PositiveModulation {
+		return "positive modulation"
+	}
+	return "negative modulation"
+}
+
+// Params is the table of constants that defines a video standard. Durations
+// are in seconds from the leading edge of line sync, levels are in IRE.
+type Params struct {
+	Name   string
+	Colour ColourSystem
+
+	FrameRate        float64
+	LinesPerFrame    int
+	ActiveVideoLines int
+	// FirstActiveLine is the first picture line of field 1. Field 2 starts
+	// (LinesPerFrame+1)/2 lines later if field 1 is on top, LinesPerFrame/2
+	// lines later if field 2 is; the top field has the extra line when
+	// ActiveVideoLines is odd.
+	FirstActiveLine int
+	FieldOrder      FieldOrder
+	// Progressive standards send two identical fields per frame, with no
+	// half-line offset, each drawn from the whole ActiveVideoLines raster.
+	Progressive bool
+	// RasterWidth and RasterHeight give the size of the raw RGB frame the
+	// picture is drawn from. Picture lines past the bottom row are black.
+	RasterWidth  int
+	RasterHeight int
+	// FieldSyncLine is the line on which field 1's broad pulses start. Field
+	// sync is counted in half-line pulses: BroadPulses broad pulses with
+	// EqualisingPulses equalising pulses on either side. Field 2's sequence
+	// starts half a frame, LinesPerFrame half-lines, later.
+	FieldSyncLine    int
+	EqualisingPulses int
+	BroadPulses      int
+
+	HSync        float64
+	BroadPulse   float64
+	EqPulse      float64
+	BurstStart   float64
+	BurstLength  float64
+	ActiveStart  float64
+	ActiveLength float64
+
+	Fsc       float64
+	Bandwidth float64 // nominal luminance bandwidth, Hz
+	Vestige   float64 // width of the lower sideband left in the RF signal, Hz
+	// Polarity and ResidualCarrier set the AM carrier levels. The residual
+	// carrier is the fraction of peak carrier left at the level furthest
+	// from peak: white on negative modulation, sync tip on positive.
+	Polarity        Polarity
+	ResidualCarrier float64
+
+	LevelSync      float64
+	LevelBlanking  float64
+	LevelBlack     float64
+	LevelWhite     float64
+	BurstAmplitude float64
+}
+
+var ntscM = Params{
+	Name:             "NTSC-M",
+	Colour:           ColourNTSC,
+	FrameRate:        30000.0 / 1001.0,
+	LinesPerFrame:    525,
+	ActiveVideoLines: 483,
+	FirstActiveLine:  22,
+	FieldOrder:       TopFieldFirst,
+	RasterWidth:      720,
+	RasterHeight:     480,
+	FieldSyncLine:    4,
+	EqualisingPulses: 6,
+	BroadPulses:      6,
+	HSync:            4.7e-6,
+	BroadPulse:       27.1e-6,
+	EqPulse:          2.3e-6,
+	BurstStart:       5.6e-6,
+	BurstLength:      2.5e-6,
+	ActiveStart:      10.7e-6,
+	ActiveLength:     52.6e-6,
+	Fsc:              315.0e6 / 88.0,
+	Bandwidth:        4.2e6,
+	Vestige:          0.75e6,
+	ResidualCarrier:  0.125,
+	LevelSync:        -40.0,
+	LevelBlanking:    0.0,
+	LevelBlack:       7.5,
+	LevelWhite:       100.0,
+	BurstAmplitude:   20.0,
+}
+
+var palBG = Params{
+	Name:             "PAL-B/G",
+	Colour:           ColourPAL,
+	FrameRate:        25.0,
+	LinesPerFrame:    625,
+	ActiveVideoLines: 575,
+	FirstActiveLine:  24,
+	FieldOrder:       BottomFieldFirst,
+	RasterWidth:      720,
+	RasterHeight:     576,
+	FieldSyncLine:    1,
+	EqualisingPulses: 5,
+	BroadPulses:      5,
+	HSync:            4.7e-6,
+	BroadPulse:       27.3e-6,
+	EqPulse:          2.35e-6,
+	BurstStart:       5.6e-6,
+	BurstLength:      2.25e-6,
+	ActiveStart:      10.5e-6,
+	ActiveLength:     52.0e-6,
+	Fsc:              4433618.75,
+	Bandwidth:        5.0e6,
+	Vestige:          0.75e6,
+	ResidualCarrier:  0.125,
+	LevelSync:        -40.0,
+	LevelBlanking:    0.0,
+	LevelBlack:       0.0,
+	LevelWhite:       100.0,
+	BurstAmplitude:   20.0,
+}
+
+// Presets maps the names accepted by -standard to their parameter tables.
+var Presets = map[string]Params{
+	"ntsc":     ntscM,
+	"ntsc-j":   with(ntscM, func(p *Params) { p.Name, p.LevelBlack = "NTSC-J", 0.0 }),
+	"ntsc-443": with(ntscM, func(p *Params) { p.Name, p.Fsc = "NTSC-4.43", 4433618.75 }),
+	"pal":      palBG,
+	"pal-m": with(ntscM, func(p *Params) {
+		p.Name, p.Colour, p.Fsc = "PAL-M", ColourPAL, 3575611.49
+	}),
+	"pal-n": with(palBG, func(p *Params) {
+		p.Name, p.Fsc, p.Bandwidth, p.LevelBlack = "PAL-N", 3582056.25, 4.2e6, 7.5
+	}),
+	"pal-60": with(ntscM, func(p *Params) {
+		p.Name, p.Colour, p.Fsc, p.LevelBlack = "PAL-60", ColourPAL, 4433618.75, 0.0
+	}),
+	"secam": secam,
+	// System L: SECAM on a wider channel with positive modulation, sync
+	// tips at no carrier.
+	"secam-l": with(secam, func(p *Params) {
+		p.Name, p.Bandwidth, p.Vestige = "SECAM-L", 6.0e6, 1.25e6
+		p.Polarity, p.ResidualCarrier = PositiveModulation, 0
+	}),
+}
+
+var secam = with(palBG, func(p *Params) {
+	p.Name, p.Colour, p.Fsc, p.BurstStart, p.BurstLength = "SECAM", ColourSECAM, secamForDb, 5.7e-6, 0
+})
+
+// Progressive converts a 525- or 625-line standard to its non-interlaced
+// form, as sent by games consoles and home computers: 262- or 312-line
+// fields at the standard's line rate, each drawn from a 240- or 288-line
+// raster of the same width.
+func Progressive(p Params) (Params, error) {
+	switch p.LinesPerFrame {
+	case 525:
+		p.Name += " 240p"
+		p.ActiveVideoLines = 240
+	case 625:
+		// Fields a line shorter than usual leave lines 23-310 for picture,
+		// the last of them ending at the mid-line equalising pulse.
+		p.Name += " 288p"
+		p.FirstActiveLine, p.ActiveVideoLines = 23, 288
+	default:
+		return p, fmt.Errorf("no progressive mode for %d-line standards", p.LinesPerFrame)
+	}
+	lines := p.LinesPerFrame - 1
+	p.FrameRate *= float64(p.LinesPerFrame) / float64(lines)
+	p.LinesPerFrame = lines
+	p.Progressive = true
+	p.RasterHeight = p.ActiveVideoLines
+	return p, nil
+}
+
+// WithFieldOrder returns p sending its fields in the given order. The top
+// field carries the extra line of an odd picture; on 625-line standards
+// field 1 runs straight into field sync, so when it becomes the top field it
+// takes that line above the picture instead, and the picture starts a line
+// earlier (line 23 rather than 24).
+func WithFieldOrder(p Params, order FieldOrder) (Params, error) {
+	if order == TopFieldFirst && p.FieldOrder != order && !p.Progressive {
+		last := p.FirstActiveLine + (p.ActiveVideoLines+1)/2 - 1
+		if p.inFieldSync(last) {
+			p.FirstActiveLine--
+		}
+	}
+	p.FieldOrder = order
+	return p, p.Validate()
+}
+
+// with returns a copy of base with edit applied, for deriving variants.
+func with(base Params, edit func(*Params)) Params {
+	edit(&base)
+	return base
+}
+
+// syncKind is the type of sync pulse a line starts with.
+type syncKind int
+
+const (
+	syncNormal syncKind = iota
+	syncEqualising
+	syncBroad
+)
+
+// halfLineSync classifies the first (half 0) or second (half 1) half of a
+// line by its position in the field sync sequence. On a normal line the first
+// half starts with line sync and the second half has no pulse.
+func (p Params) halfLineSync(line, half int) syncKind {
+	// Half-lines are counted from the first pre-equalising pulse of field 1;
+	// field 2's sequence follows LinesPerFrame half-lines later, so on an odd
+	// line count it falls half a line out of step with field 1.
+	pos := (2*(line-p.FieldSyncLine) + half + p.EqualisingPulses) % (2 * p.LinesPerFrame)
+	if pos < 0 {
+		pos += 2 * p.LinesPerFrame
+	}
+	if pos >= p.LinesPerFrame {
+		pos -= p.LinesPerFrame
+	}
+	switch {
+	case pos < p.EqualisingPulses:
+		return syncEqualising
+	case pos < p.EqualisingPulses+p.BroadPulses:
+		return syncBroad
+	case pos < 2*p.EqualisingPulses+p.BroadPulses:
+		return syncEqualising
+	}
+	return syncNormal
+}
+
+// inFieldSync reports whether either half of a line is part of field sync.
+func (p Params) inFieldSync(line int) bool {
+	return p.halfLineSync(line, 0) != syncNormal || p.halfLineSync(line, 1) != syncNormal
+}
+
+// pictureStart returns the first picture lines of the top and bottom fields.
+// On a progressive standard these are the first lines of field 1 and 2.
+func (p Params) pictureStart() (top, bottom int) {
+	if p.Progressive {
+		return p.FirstActiveLine, p.FirstActiveLine + p.LinesPerFrame/2
+	}
+	if p.FieldOrder == BottomFieldFirst {
+		return p.FirstActiveLine + p.LinesPerFrame/2, p.FirstActiveLine
+	}
+	return p.FirstActiveLine, p.FirstActiveLine + (p.LinesPerFrame+1)/2
+}
+
+// videoLine maps a line to its row in the raw frame, or -1 for lines outside
+// the picture. The top field supplies the even rows and the bottom field the
+// odd rows.
+func (p Params) videoLine(line int) int {
+	top, bottom := p.pictureStart()
+	if p.Progressive {
+		switch {
+		case line >= top && line < top+p.ActiveVideoLines:
+			return line - top
+		case line >= bottom && line < bottom+p.ActiveVideoLines:
+			return line - bottom
+		}
+		return -1
+	}
+	switch {
+	case line >= top && line < top+(p.ActiveVideoLines+1)/2:
+		return (line - top) * 2
+	case line >= bottom && line < bottom+p.ActiveVideoLines/2:
+		return (line-bottom)*2 + 1
+	}
+	return -1
+}
+
+// field returns which field (1 or 2) a line belongs to.
+func (p Params) field(line int) int {
+	if line <= (p.LinesPerFrame+1)/2 {
+		return 1
+	}
+	return 2
+}
+
+// RenderRate is the sample rate the composite signal is best rendered at:
+// the 13.5 MHz of ITU-R BT.601, which gives a whole number of samples per line
+// on both 525- and 625-line systems and room for any standard's chroma.
+const RenderRate = 13.5e6
+
+// RenderRateFor returns the rate to render p at for a HackRF running at
+// deviceRate. Up to RenderRate the signal is rendered at the device rate and
+// sent as it is, which saves both samples and resampling, as long as the
+// colour subcarrier lies below Nyquist there. Otherwise it is rendered at
+// RenderRate and the transmitter resamples it: up to a faster device, or
+// down to a slower one with the chroma filtered out rather than folded back
+// onto the picture.
+func RenderRateFor(p Params, deviceRate float64) float64 {
+	if deviceRate > RenderRate || (p.Colour != ColourNone && p.Fsc >= deviceRate/2) {
+		return RenderRate
+	}
+	return deviceRate
+}
+
+// New builds the Standard described by p using the encoder for its colour system.
+func New(p Params, sampleRate float64) Standard {
+	switch p.Colour {
+	case ColourPAL:
+		return NewPAL(p, sampleRate)
+	case ColourSECAM:
+		return NewSECAM(p, sampleRate)
+	case ColourNone:
+		return NewMonochrome(p, sampleRate)
+	default:
+		return NewNTSC(p, sampleRate)
+	}
+}
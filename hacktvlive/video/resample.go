@@ -0,0 +1,153 @@
+package video
+
+import (
+	"fmt"
+	"math"
+)
+
+// Interpolation selects how the raster is resampled onto the sample grid of
+// an active line.
+type Interpolation int
+
+const (
+	InterpNearest Interpolation = iota
+	InterpLinear
+	InterpCubic
+	InterpLanczos
+)
+
+// Interpolations maps the names accepted by -interp to interpolation kinds.
+var Interpolations = map[string]Interpolation{
+	"nearest": InterpNearest,
+	"linear":  InterpLinear,
+	"cubic":   InterpCubic,
+	"lanczos": InterpLanczos,
+}
+
+func (k Interpolation) String() string {
+	for name, kind := range Interpolations {
+		if kind == k {
+			return name
+		}
+	}
+	return fmt.Sprintf("Interpolation(%d)", int(k))
+}
+
+// kernel returns the interpolation kernel and how far either side of the
+// output position it reaches, in raster pixels.
+func (k Interpolation) kernel() (func(x float64) float64, float64) {
+	switch k {
+	case InterpLinear:
+		return func(x float64) float64 { return math.Max(0, 1-math.Abs(x)) }, 1
+	case InterpCubic:
+		// Catmull-Rom, the Keys cubic with a = -0.5.
+		return func(x float64) float64 {
+			x = math.Abs(x)
+			switch {
+			case x < 1:
+				return 1.5*x*x*x - 2.5*x*x + 1
+			case x < 2:
+				return -0.5*x*x*x + 2.5*x*x - 4*x + 2
+			}
+			return 0
+		}, 2
+	case InterpLanczos:
+		// Lanczos-3.
+		return func(x float64) float64 {
+			if x == 0 {
+				return 1
+			}
+			if math.Abs(x) >= 3 {
+				return 0
+			}
+			px := math.Pi * x
+			return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+		}, 3
+	}
+	return func(x float64) float64 {
+		if x >= -0.5 && x < 0.5 {
+			return 1
+		}
+		return 0
+	}, 0.5
+}
+
+// DefaultInterpolation is used unless SetInterpolation chooses another.
+const DefaultInterpolation = InterpLinear
+
+// resamplePhases is how finely the position of a sample between two raster
+// pixels is resolved when picking its precomputed weights.
+const resamplePhases = 64
+
+// resampler maps the raster pixels of a row onto the samples of an active
+// line. Its weights are computed once per standard for each of
+// resamplePhases sub-pixel positions, so a line costs one table lookup and a
+// few multiply-adds per sample.
+type resampler struct {
+	step    float64   // raster pixels per sample
+	taps    int       // weights per sample
+	weights []float64 // taps weights for each phase
+}
+
+// newResampler precomputes the weights for resampling rows of a raster
+// width pixels wide onto an active line activeLength seconds long.
+func newResampler(kind Interpolation, width int, activeLength, sampleRate float64) *resampler {
+	rs := &resampler{step: float64(width) / (activeLength * sampleRate)}
+	kern, support := kind.kernel()
+
+	// When there are more pixels than samples the kernel is widened so it
+	// averages the pixels between samples instead of skipping them.
+	scale := math.Max(1, rs.step)
+	if kind == InterpNearest {
+		scale = 1
+	}
+	// One more tap than the kernel is wide, so the kernel's reach is covered
+	// wherever the sample falls between two pixels.
+	rs.taps = int(math.Ceil(2*support*scale)) + 1
+	rs.weights = make([]float64, resamplePhases*rs.taps)
+	for phase := 0; phase < resamplePhases; phase++ {
+		frac := (float64(phase) + 0.5) / resamplePhases
+		w := rs.weights[phase*rs.taps : (phase+1)*rs.taps]
+		var sum float64
+		for j := range w {
+			w[j] = kern((float64(rs.first()+j) - frac) / scale)
+			sum += w[j]
+		}
+		for j := range w {
+			w[j] /= sum
+		}
+	}
+	return rs
+}
+
+// first is the offset of the first tap from the pixel at or before the
+// sample position.
+func (rs *resampler) first() int {
+	return -(rs.taps - 1) / 2
+}
+
+// resampleRow fills rgb, three values per sample, from a raster row. x0 is
+// the position of the first sample in raster pixels, with pixel centres on
+// whole numbers. Samples beyond the ends of the row are black.
+func (rs *resampler) resampleRow(rgb []float64, row []byte, x0 float64) {
+	width := len(row) / 3
+	for k := 0; k < len(rgb)/3; k++ {
+		pos := x0 + float64(k)*rs.step
+		out := rgb[k*3 : k*3+3]
+		if pos < -0.5 || pos >= float64(width)-0.5 {
+			out[0], out[1], out[2] = 0, 0, 0
+			continue
+		}
+		base := math.Floor(pos)
+		phase := min(int((pos-base)*resamplePhases), resamplePhases-1)
+		w := rs.weights[phase*rs.taps : (phase+1)*rs.taps]
+		var r, g, b float64
+		for j, weight := range w {
+			px := min(max(int(base)+rs.first()+j, 0), width-1) * 3
+			r += weight * float64(row[px])
+			g += weight * float64(row[px+1])
+			b += weight * float64(row[px+2])
+		}
+		out[0], out[1], out[2] = r, g, b
+	}
+}
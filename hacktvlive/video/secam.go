@@ -0,0 +1,174 @@
+package video
+
+import (
+	"math"
+
+	"hacktvlive/dsp"
+)
+
+// SECAM encodes chroma as frequency-modulated Dr and Db subcarriers sent on
+// alternate lines.
+type SECAM struct {
+	encoder
+	idRampSamples    int
+	chromaAmplitude  float64
+	preEmphasisAlpha float64
+	preEmphasisGain  float64
+}
+
+// SECAM subcarrier rest frequencies, deviations and limits. The FM deviation
+// is clipped to the limits.
+const (
+	secamForDr       = 4406250.0
+	secamForDb       = 4250000.0
+	secamDeviationDr = 280000.0
+	secamDeviationDb = 230000.0
+	secamBellCentre  = 4286000.0
+	secamMinFreq     = 3900000.0
+	secamMaxFreq     = 4756000.0
+)
+
+// NewSECAM creates a new SECAM standard object from a parameter table.
+func NewSECAM(p Params, sampleRate float64) *SECAM {
+	s := &SECAM{}
+	s.init(p, sampleRate)
+
+	// The undeviated subcarrier is 23% of the luminance range peak-to-peak.
+	s.chromaAmplitude = 0.23 * (p.LevelWhite - p.LevelBlack) / 2.0
+
+	// Low-frequency pre-emphasis of the colour-difference signals:
+	// (1 + jf/85kHz) / (1 + jf/255kHz), realised as a first-order shelf.
+	s.preEmphasisAlpha = 1.0 - math.Exp(-2.0*math.Pi*255000.0/sampleRate)
+	s.preEmphasisGain = 255000.0 / 85000.0
+
+	s.idRampSamples = int(15.0e-6 * sampleRate)
+	return s
+}
+
+// GenerateFullFrame creates a complete SECAM frame from the raw pixel data.
+// Dr and Db are sent on alternate lines; the alternation runs on across
+// frame boundaries, so it reverses every frame as the standard requires.
+func (s *SECAM) GenerateFullFrame() {
+	s.generateFrame(s)
+}
+
+// GenerateField creates the next SECAM field on its own.
+func (s *SECAM) GenerateField() {
+	s.generateField(s)
+}
+
+func (s *SECAM) addChroma(line int, lineBuffer []float64, sc *scratch) {
+	switch {
+	case s.isIdentificationLine(line):
+		s.addIdentification(line, lineBuffer)
+	case s.isPicture(line):
+		s.addColourDifference(line, lineBuffer, sc)
+	}
+}
+
+// isDrLine reports whether a line carries Dr rather than Db. The two
+// alternate on every line sent, starting with Dr on line 1 of the first
+// frame; with an odd number of lines a frame the pattern reverses each frame.
+func (s *SECAM) isDrLine(line int) bool {
+	return (s.frame*uint64(s.params.LinesPerFrame)+uint64(line-1))%2 == 0
+}
+
+// addColourDifference frequency-modulates the line's colour-difference signal
+// onto its subcarrier. The subcarrier starts undeviated on the back porch,
+// which doubles as horizontal line identification.
+func (s *SECAM) addColourDifference(line int, lineBuffer []float64, sc *scratch) {
+	dr := s.isDrLine(line)
+	rest, deviation := secamForDb, secamDeviationDb
+	if dr {
+		rest, deviation = secamForDr, secamDeviationDr
+	}
+
+	// The subcarrier is switched on at the start of the back porch, which
+	// the parameter table gives as the burst start.
+	lt := s.timing(line)
+	osc := dsp.NewNCO(s.startPhase(line), rest, s.sampleRate)
+	var lowPass float64
+	for n := lt.burstStart; n < lt.activeEnd; n++ {
+		freq := rest
+		if n >= lt.activeStart {
+			d := s.getPixelD(sc, n, dr)
+			lowPass += s.preEmphasisAlpha * (d - lowPass)
+			d = lowPass + s.preEmphasisGain*(d-lowPass)
+			freq = rest + deviation*d
+		}
+		freq = math.Max(secamMinFreq, math.Min(secamMaxFreq, freq))
+		osc.Tune(freq, s.sampleRate)
+		_, cos := osc.Next()
+		lineBuffer[n] += s.bellAmplitude(freq) * cos
+	}
+}
+
+// addIdentification emits the vertical identification signal: a trapezoid
+// deviating towards 4.756 MHz on Dr lines and 3.900 MHz on Db lines.
+func (s *SECAM) addIdentification(line int, lineBuffer []float64) {
+	rest, peak := secamForDb, secamMinFreq
+	if s.isDrLine(line) {
+		rest, peak = secamForDr, secamMaxFreq
+	}
+
+	lt := s.timing(line)
+	osc := dsp.NewNCO(s.startPhase(line), rest, s.sampleRate)
+	for n := lt.burstStart; n < lt.activeEnd; n++ {
+		freq := rest
+		if n >= lt.activeStart {
+			ramp := math.Min(1.0, float64(n-lt.activeStart)/float64(s.idRampSamples))
+			freq = rest + (peak-rest)*ramp
+		}
+		osc.Tune(freq, s.sampleRate)
+		_, cos := osc.Next()
+		lineBuffer[n] += s.bellAmplitude(freq) * cos
+	}
+}
+
+// startPhase returns the subcarrier phase at the start of a line. The phase
+// is inverted on every third line and on every second field to reduce the
+// visibility of the subcarrier in the picture.
+func (s *SECAM) startPhase(line int) float64 {
+	phase := 0.0
+	if line%3 == 0 {
+		phase += math.Pi
+	}
+	if line > s.params.LinesPerFrame/2 {
+		phase += math.Pi
+	}
+	return phase
+}
+
+// bellAmplitude applies the high-frequency "cloche" pre-emphasis, which
+// raises the subcarrier amplitude the further it is deviated from 4.286 MHz.
+func (s *SECAM) bellAmplitude(freq float64) float64 {
+	f := freq/secamBellCentre - secamBellCentre/freq
+	return s.chromaAmplitude * math.Sqrt((1+256*f*f)/(1+1.5876*f*f))
+}
+
+// isIdentificationLine reports whether a line carries vertical identification:
+// lines 7-15 of each field, counted from the start of the broad pulses.
+func (s *SECAM) isIdentificationLine(line int) bool {
+	p := s.params
+	rel := line - p.FieldSyncLine
+	if rel >= (p.LinesPerFrame+1)/2 {
+		rel -= (p.LinesPerFrame + 1) / 2
+	}
+	return rel >= 6 && rel <= 14
+}
+
+// getPixelD returns the colour-difference signal carried on this line:
+// Dr = -1.902(R-Y) on Dr lines, Db = 1.505(B-Y) on Db lines.
+func (s *SECAM) getPixelD(sc *scratch, sampleInLine int, dr bool) float64 {
+	r, g, b, ok := s.getPixelRGB(sc, sampleInLine)
+	if !ok {
+		return 0
+	}
+	r, g, b = r/255.0, g/255.0, b/255.0
+
+	yVal := 0.299*r + 0.587*g + 0.114*b
+	if dr {
+		return -1.902 * (r - yVal)
+	}
+	return 1.505 * (b - yVal)
+}
@@ -1,16 +1,35 @@
 package video
 
-// Video source resolution we will ask FFmpeg to produce
-const (
-	FrameWidth  = 540
-	FrameHeight = 480
-)
-
 // Standard defines the interface for a video signal standard like NTSC or PAL.
 type Standard interface {
 	GenerateFullFrame()
+	// GenerateField renders the next field alone into the frame buffer
+	GenerateField()
 	FillTestPattern()
+	FrameRate() float64
+	// SampleRate is the rate the composite signal is rendered at
+	SampleRate() float64
+	// Params returns the parameter table the standard was built from
+	Params() Params
+	// Raw frame size and the rate the source should refresh it at
+	RasterSize() (width, height int)
+	SourceRate() float64
+	SetFieldRateMotion(bool)
+	// SetFilters chooses the luma and chroma band-limiting
+	SetFilters(FilterOptions)
+	// SetInterpolation chooses how the raster is resampled horizontally
+	SetInterpolation(Interpolation)
+	// SetWorkers sets how many lines are rendered in parallel, 0 for one per CPU
+	SetWorkers(int)
 	IreToAmplitude(float64) float64
+	// AddInserter registers a VBI data inserter on the given lines
+	AddInserter(ins LineInserter, lines ...int) error
+	// FreeLines returns those of lines no inserter has claimed that one
+	// could be added to
+	FreeLines(lines []int) []int
+	// Frame sequence counter and timecode burn-in
+	FrameNumber() uint64
+	SetTimecodeBurnIn(bool)
 	// Mutex for the final, generated frame (NTSC/PAL signal)
 	LockFrame()
 	UnlockFrame()
@@ -0,0 +1,50 @@
+package video
+
+// TeletextSource supplies 42-byte WST packets (address and data bytes) for
+// the VBI lines a TeletextInserter is added to. A nil packet leaves the line
+// blank.
+type TeletextSource interface {
+	NextPacket() []byte
+}
+
+// World System Teletext on 625-line systems: 444 times the line rate NRZ,
+// clock run-in and framing code first, ones at 66 IRE above black.
+const (
+	teletextStart     = 11.5e-6
+	teletextLevel     = 66.0
+	teletextBandwidth = 5.0e6
+)
+
+// teletextPrefix is the clock run-in (two 0x55 bytes) and framing code 0x27.
+var teletextPrefix = byteBits(0x55, 0x55, 0x27)
+
+// DefaultTeletextLines are the VBI lines used for teletext unless told otherwise.
+var DefaultTeletextLines = []int{
+	7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22,
+	320, 321, 322, 323, 324, 325, 326, 327, 328, 329, 330, 331, 332, 333, 334, 335,
+}
+
+// TeletextInserter writes one packet from its source onto each line it runs on.
+type TeletextInserter struct {
+	src TeletextSource
+}
+
+// NewTeletextInserter creates an inserter for teletext packets from src.
+func NewTeletextInserter(src TeletextSource) *TeletextInserter {
+	return &TeletextInserter{src: src}
+}
+
+// InsertLine adds the next packet to the line, if the source has one.
+func (t *TeletextInserter) InsertLine(info LineInfo, lineBuffer []float64) {
+	pkt := t.src.NextPacket()
+	if pkt == nil {
+		return
+	}
+	p := info.Params
+	bitRate := 444.0 * p.FrameRate * float64(p.LinesPerFrame)
+	bitSamples := info.SampleRate / bitRate
+	rolloff := nrzRolloff(bitRate, teletextBandwidth, info.SampleRate)
+
+	bits := append(append([]bool{}, teletextPrefix...), byteBits(pkt...)...)
+	writeNRZ(lineBuffer, bits, info.Sample(teletextStart), bitSamples, teletextLevel, rolloff)
+}
@@ -0,0 +1,57 @@
+package video
+
+import "math"
+
+// maxTemplates caps the sync templates kept per encoder. Standards at the
+// usual sample rates need a handful; a rate that makes nearly every line start
+// at a different point between samples draws the rest afresh.
+const maxTemplates = 1024
+
+// templateKey identifies the sync and blanking of a line. Lines with the same
+// pulses, length and start between samples look the same until picture and
+// chroma go on.
+type templateKey struct {
+	first, second syncKind
+	samples       int
+	origin        float64 // to the nearest millionth of a sample
+}
+
+// syncTemplate returns the blanking level and sync pulses of a line laid out
+// as lt, drawn once and shared by every line that matches.
+func (e *encoder) syncTemplate(line int, lt lineTiming) []float64 {
+	key := templateKey{
+		first:   e.params.halfLineSync(line, 0),
+		second:  e.params.halfLineSync(line, 1),
+		samples: lt.samples,
+		origin:  math.Round(lt.origin*1e6) / 1e6,
+	}
+	if t, ok := e.templates[key]; ok {
+		return t
+	}
+	t := e.drawSync(line, lt)
+	if len(e.templates) < maxTemplates {
+		if e.templates == nil {
+			e.templates = make(map[templateKey][]float64)
+		}
+		e.templates[key] = t
+	}
+	return t
+}
+
+// drawSync renders the sync and blanking of a line.
+func (e *encoder) drawSync(line int, lt lineTiming) []float64 {
+	p := e.params
+	t := make([]float64, lt.samples)
+	for s := range t {
+		t[s] = p.LevelBlanking
+	}
+
+	halfLine := 0.5 / (p.FrameRate * float64(p.LinesPerFrame))
+	for half := 0; half < 2; half++ {
+		if pulse := e.syncPulse(line, half); pulse > 0 {
+			start := float64(half) * halfLine
+			fillPulse(t, lt.pos(start, e.sampleRate), lt.pos(start+pulse, e.sampleRate), p.LevelSync)
+		}
+	}
+	return t
+}
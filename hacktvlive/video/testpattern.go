@@ -1,7 +1,7 @@
 package video
 
 // FillColorBars fills the rawFrameBuffer with a standard SMPTE color bars pattern.
-func FillColorBars(buf []byte) {
+func FillColorBars(buf []byte, width, height int) {
 	// SMPTE color bars: 7 vertical stripes
 	barColors := [7][3]uint8{
 		{192, 192, 192}, // Gray
@@ -12,14 +12,14 @@ func FillColorBars(buf []byte) {
 		{192, 0, 0},     // Red
 		{0, 0, 192},     // Blue
 	}
-	barWidth := FrameWidth / 7
-	for y := 0; y < FrameHeight; y++ {
-		for x := 0; x < FrameWidth; x++ {
+	barWidth := width / 7
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
 			barIdx := x / barWidth
 			if barIdx >= 7 {
 				barIdx = 6
 			}
-			i := (y*FrameWidth + x) * 3
+			i := (y*width + x) * 3
 			buf[i] = barColors[barIdx][0]
 			buf[i+1] = barColors[barIdx][1]
 			buf[i+2] = barColors[barIdx][2]
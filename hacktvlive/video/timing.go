@@ -0,0 +1,130 @@
+package video
+
+import "math"
+
+// lineTiming places one line on the sample grid. A line rarely lasts a whole
+// number of samples, so line boundaries fall between samples. Each line
+// starts on the sample at or before its exact start and every timing within
+// the line is measured from that exact start, so the long-term line and frame
+// rates match the standard exactly.
+type lineTiming struct {
+	offset  int       // first sample of the line in the frame buffer
+	samples int       // samples until the next line starts
+	origin  float64   // exact start of the line, in samples after the first (0 to 1)
+	index   uint64    // position of the line in the colour framing sequence
+	phase   float64   // subcarrier phase at the line's first sample, radians
+	sync    []float64 // blanking and sync pulses, shared with lines like it
+
+	burstStart, burstEnd   int
+	activeStart, activeEnd int
+}
+
+// pos returns the position in samples, within the line, of a time in seconds
+// from the leading edge of sync.
+func (lt lineTiming) pos(t, sampleRate float64) float64 {
+	return lt.origin + t*sampleRate
+}
+
+// at returns the first sample at or after a time from the leading edge of sync.
+func (lt lineTiming) at(t, sampleRate float64) int {
+	return int(math.Ceil(lt.pos(t, sampleRate) - 1e-9))
+}
+
+// initTiming works out the frame sequence over which the sample pattern of
+// line lengths repeats, and sizes the frame buffer for the longest frame.
+func (e *encoder) initTiming() {
+	p := e.params
+	e.samplesPerFrame = e.sampleRate / p.FrameRate
+	e.samplesPerLine = e.samplesPerFrame / float64(p.LinesPerFrame)
+
+	// Most standards repeat after a few frames (3 for NTSC at 8 Msps); if
+	// the frame length is irrational in samples the layout simply never
+	// repeats.
+	e.framePeriod = 0
+	for n := 1; n <= 1000; n++ {
+		total := float64(n) * e.samplesPerFrame
+		if math.Abs(total-math.Round(total)) < 1e-6 {
+			e.framePeriod = uint64(n)
+			break
+		}
+	}
+
+	// The subcarrier has a whole number of cycles over the colour framing
+	// sequence: 2 frames (4 fields) for NTSC, 4 frames (8 fields) for PAL.
+	e.colourFrames = 0
+	for n := 1; n <= 1000; n++ {
+		cycles := float64(n) * p.Fsc / p.FrameRate
+		if math.Abs(cycles-math.Round(cycles)) < 1e-3 {
+			e.colourFrames = uint64(n)
+			break
+		}
+	}
+
+	e.frameStore = make([]float64, int(math.Ceil(e.samplesPerFrame))+1)
+	e.lines = make([]lineTiming, p.LinesPerFrame)
+}
+
+// layoutFrame places the lines of the frame about to be generated and sizes
+// the frame buffer to match.
+func (e *encoder) layoutFrame() {
+	p := e.params
+	f := e.frame
+	if e.framePeriod > 0 {
+		f %= e.framePeriod
+	}
+	frameStart := float64(f) * e.samplesPerFrame
+	first := math.Floor(frameStart + 1e-6)
+
+	// The subcarrier runs on from frame to frame. Its phase is measured from
+	// the leading edge of line 1 of the first frame of the colour sequence,
+	// where SC-H phase puts the reference (U on PAL) at 0 degrees.
+	c := e.frame
+	if e.colourFrames > 0 {
+		c %= e.colourFrames
+	}
+	lineDuration := 1.0 / (p.FrameRate * float64(p.LinesPerFrame))
+	frontPorch := lineDuration - p.ActiveStart - p.ActiveLength
+
+	for i := range e.lines {
+		x := frameStart + float64(i)*e.samplesPerLine
+		start := math.Floor(x + 1e-6)
+		next := math.Floor(x + e.samplesPerLine + 1e-6)
+		lt := lineTiming{
+			offset:  int(start - first),
+			samples: int(next - start),
+			origin:  math.Max(0, x-start),
+			index:   c*uint64(p.LinesPerFrame) + uint64(i),
+		}
+		cycles := p.Fsc * (float64(lt.index)*lineDuration - lt.origin/e.sampleRate)
+		lt.phase = 2.0 * math.Pi * (cycles - math.Floor(cycles))
+		lt.burstStart = lt.at(p.BurstStart, e.sampleRate)
+		lt.burstEnd = lt.at(p.BurstStart+p.BurstLength, e.sampleRate)
+		lt.activeStart = lt.at(p.ActiveStart, e.sampleRate)
+		lt.activeEnd = min(lt.at(p.ActiveStart+p.ActiveLength, e.sampleRate), lt.samples)
+		if p.halfLineSync(i+1, 1) != syncNormal {
+			// The picture stops a front porch before a mid-line pulse.
+			lt.activeEnd = min(lt.activeEnd, lt.at(lineDuration/2-frontPorch, e.sampleRate))
+		}
+		lt.sync = e.syncTemplate(i+1, lt)
+		e.lines[i] = lt
+	}
+
+	last := e.lines[len(e.lines)-1]
+	e.frameBuffer = e.frameStore[:last.offset+last.samples]
+}
+
+// fillPulse sets a line to level between two fractional sample positions.
+// Each sample stands for the half sample either side of it, so the samples
+// an edge falls in are blended in proportion and the edge keeps its
+// sub-sample timing.
+func fillPulse(lineBuffer []float64, from, to, level float64) {
+	first := max(int(math.Floor(from+0.5)), 0)
+	last := min(int(math.Ceil(to+0.5)), len(lineBuffer))
+	for s := first; s < last; s++ {
+		cover := math.Min(to, float64(s)+0.5) - math.Max(from, float64(s)-0.5)
+		if cover <= 0 {
+			continue
+		}
+		lineBuffer[s] += math.Min(cover, 1) * (level - lineBuffer[s])
+	}
+}
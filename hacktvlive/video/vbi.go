@@ -0,0 +1,59 @@
+package video
+
+import "math"
+
+// nrzSpan is how many bit periods either side of its centre a shaped pulse
+// is allowed to spread.
+const nrzSpan = 4
+
+// writeNRZ adds a band-limited NRZ data waveform to a line. Bit k is centred
+// at start + (k+0.5)*bitSamples and a one raises the level by amplitude. Each
+// bit is shaped by a raised-cosine pulse with the given roll-off, so the
+// waveform stays inside the channel even when a bit is only a sample or two
+// long.
+func writeNRZ(lineBuffer []float64, bits []bool, start, bitSamples, amplitude, rolloff float64) {
+	first := int(math.Floor(start - nrzSpan*bitSamples))
+	last := int(math.Ceil(start + (float64(len(bits))+nrzSpan)*bitSamples))
+	for n := max(first, 0); n < min(last, len(lineBuffer)); n++ {
+		t := (float64(n) - start) / bitSamples
+		var level float64
+		for k := max(int(t)-nrzSpan, 0); k <= min(int(t)+nrzSpan, len(bits)-1); k++ {
+			if bits[k] {
+				level += raisedCosine(t-float64(k)-0.5, rolloff)
+			}
+		}
+		lineBuffer[n] += amplitude * level
+	}
+}
+
+// nrzRolloff returns the widest raised-cosine roll-off, between 0.1 and 1,
+// that keeps NRZ data at bitRate inside both bandwidth and the sample rate.
+func nrzRolloff(bitRate, bandwidth, sampleRate float64) float64 {
+	rolloff := 2.0*math.Min(bandwidth, 0.48*sampleRate)/bitRate - 1.0
+	return math.Max(0.1, math.Min(1.0, rolloff))
+}
+
+// raisedCosine is the impulse response of a raised-cosine filter, with x in
+// bit periods.
+func raisedCosine(x, rolloff float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	sinc := math.Sin(math.Pi*x) / (math.Pi * x)
+	d := 2 * rolloff * x
+	if math.Abs(math.Abs(d)-1) < 1e-9 {
+		return math.Pi / 4 * sinc
+	}
+	return sinc * math.Cos(math.Pi*rolloff*x) / (1 - d*d)
+}
+
+// byteBits returns the bits of data, least significant bit first.
+func byteBits(data ...byte) []bool {
+	bits := make([]bool, 0, len(data)*8)
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			bits = append(bits, b&(1<<i) != 0)
+		}
+	}
+	return bits
+}
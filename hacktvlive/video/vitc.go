@@ -0,0 +1,189 @@
+package video
+
+import (
+	"fmt"
+	"math"
+)
+
+// SMPTE 12M vertical interval timecode: 90 bits at 115 times the line rate
+// (116 times on 625-line systems, 1.8125 Mb/s), nine groups of a "10" sync
+// pair and eight data bits, the last group being a CRC. Ones are sent at 80
+// IRE.
+const (
+	vitcBitsPerLine525 = 115.0
+	vitcBitsPerLine625 = 116.0
+	vitcLevel          = 80.0
+	vitcStart525       = 10.0e-6
+	vitcStart625       = 11.2e-6
+)
+
+// Timecode is a SMPTE hours:minutes:seconds:frames timecode.
+type Timecode struct {
+	Hours, Minutes, Seconds, Frames int
+	DropFrame                       bool
+}
+
+// TimecodeAt converts a frame sequence number to timecode. Rates of
+// 30000/1001 use drop-frame counting so the timecode keeps pace with the
+// clock.
+func TimecodeAt(frame uint64, frameRate float64) Timecode {
+	fps := uint64(math.Round(frameRate))
+	drop := fps == 30 && frameRate != 30
+	if drop {
+		// Frames 0 and 1 are skipped at the start of every minute except
+		// each tenth one.
+		tens, rem := frame/17982, frame%17982
+		frame += 18 * tens
+		if rem > 1 {
+			frame += 2 * ((rem - 2) / 1798)
+		}
+	}
+	return Timecode{
+		Hours:     int(frame / (fps * 3600) % 24),
+		Minutes:   int(frame / (fps * 60) % 60),
+		Seconds:   int(frame / fps % 60),
+		Frames:    int(frame % fps),
+		DropFrame: drop,
+	}
+}
+
+// String formats the timecode, with the customary ';' before the frames when
+// drop-frame counting is in use.
+func (tc Timecode) String() string {
+	sep := ':'
+	if tc.DropFrame {
+		sep = ';'
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%c%02d", tc.Hours, tc.Minutes, tc.Seconds, sep, tc.Frames)
+}
+
+// VITCInserter sends the standard's frame counter as vertical interval
+// timecode. It is usually added to two lines in each field.
+type VITCInserter struct{}
+
+// DefaultVITCLines returns the usual field 1 VITC lines: 14 and 16 on
+// 525-line systems, 19 and 21 on 625-line systems.
+func DefaultVITCLines(p Params) []int {
+	if p.LinesPerFrame == 525 {
+		return []int{14, 16}
+	}
+	return []int{19, 21}
+}
+
+// VITCLines adds the matching field 2 lines to a list of field 1 lines.
+func VITCLines(p Params, field1 []int) []int {
+	lines := append([]int{}, field1...)
+	for _, line := range field1 {
+		lines = append(lines, line+(p.LinesPerFrame+1)/2)
+	}
+	return lines
+}
+
+// InsertLine adds the timecode of the frame being generated to the line.
+func (VITCInserter) InsertLine(info LineInfo, lineBuffer []float64) {
+	p := info.Params
+	tc := TimecodeAt(info.Frame, p.FrameRate)
+
+	var data uint64
+	data |= uint64(tc.Frames%10) | uint64(tc.Frames/10)<<8
+	data |= uint64(tc.Seconds%10)<<16 | uint64(tc.Seconds/10)<<24
+	data |= uint64(tc.Minutes%10)<<32 | uint64(tc.Minutes/10)<<40
+	data |= uint64(tc.Hours%10)<<48 | uint64(tc.Hours/10)<<56
+	if tc.DropFrame {
+		data |= 1 << 10
+	}
+	// The field mark sits in bit 27 on 525-line systems and bit 59 on
+	// 625-line systems.
+	if info.Field == 2 {
+		if p.LinesPerFrame == 525 {
+			data |= 1 << 27
+		} else {
+			data |= 1 << 59
+		}
+	}
+
+	bits := make([]bool, 0, 90)
+	for group := 0; group < 8; group++ {
+		bits = append(bits, true, false)
+		for i := 0; i < 8; i++ {
+			bits = append(bits, data&(1<<(group*8+i)) != 0)
+		}
+	}
+	bits = append(bits, true, false)
+	bits = append(bits, vitcCRC(bits)...)
+
+	start, bitsPerLine := vitcStart625, vitcBitsPerLine625
+	if p.LinesPerFrame == 525 {
+		start, bitsPerLine = vitcStart525, vitcBitsPerLine525
+	}
+	bitRate := bitsPerLine * p.FrameRate * float64(p.LinesPerFrame)
+	rolloff := nrzRolloff(bitRate, p.Bandwidth, info.SampleRate)
+	writeNRZ(lineBuffer, bits, info.Sample(start), info.SampleRate/bitRate, vitcLevel, rolloff)
+}
+
+// vitcCRC returns the CRC (x^8 + 1) of the 82 bits before it, in
+// transmission order.
+func vitcCRC(bits []bool) []bool {
+	crc := 0
+	for _, bit := range bits {
+		feedback := crc >> 7 & 1
+		if bit {
+			feedback ^= 1
+		}
+		crc = crc<<1&0xFF | feedback
+	}
+	return appendMSBFirst(nil, crc, 8)
+}
+
+// burnInScale is the size in raw pixels of each dot of the burn-in font.
+const burnInScale = 3
+
+// burnInFont holds 5x7 glyphs for the timecode digits and separators, one
+// byte per row with the leftmost dot in bit 4.
+var burnInFont = map[rune][7]byte{
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	';': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x04, 0x08},
+}
+
+// SetTimecodeBurnIn turns the on-screen timecode in the top left corner of
+// the picture on or off.
+func (e *encoder) SetTimecodeBurnIn(on bool) {
+	e.burnIn = on
+}
+
+// drawTimecode burns the timecode of the frame being generated into the
+// field's copy of the raw frame, white on a black box.
+func (e *encoder) drawTimecode() {
+	text := TimecodeAt(e.frame, e.params.FrameRate).String()
+	const x0, y0, pad = 16, 16, 4
+	width := len(text)*6*burnInScale + 2*pad
+	height := 7*burnInScale + 2*pad
+
+	rasterWidth, rasterHeight := e.RasterSize()
+	for y := y0; y < y0+height && y < rasterHeight; y++ {
+		for x := x0; x < x0+width && x < rasterWidth; x++ {
+			cx, cy := (x-x0-pad)/burnInScale, (y-y0-pad)/burnInScale
+			on := false
+			if x >= x0+pad && y >= y0+pad && cx/6 < len(text) && cx%6 < 5 && cy < 7 {
+				glyph := burnInFont[rune(text[cx/6])]
+				on = glyph[cy]&(0x10>>(cx%6)) != 0
+			}
+			var v byte
+			if on {
+				v = 0xFF
+			}
+			i := (y*rasterWidth + x) * 3
+			e.picture[i], e.picture[i+1], e.picture[i+2] = v, v, v
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TestSignal is a vertical interval test signal. It is a LineInserter that
+// replaces the blank content of the lines it is added to.
+type TestSignal int
+
+const (
+	// VITSComposite is the NTC-7 composite line: white bar, 2T pulse, 12.5T
+	// modulated pulse and a modulated staircase.
+	VITSComposite TestSignal = iota
+	// VITSMultiburst is a white flag followed by six frequency packets on a
+	// 50% pedestal (NTC-7 multiburst, or ITS line 18 on 625-line systems).
+	VITSMultiburst
+	// VITSStaircase is a six-level staircase carrying subcarrier on every step.
+	VITSStaircase
+	// VITSPulseBar is the 2T pulse followed by a white bar.
+	VITSPulseBar
+	// VITSITS17 is the CCIR line 17: white bar, 2T pulse, 20T modulated pulse
+	// and a five-step staircase.
+	VITSITS17
+	// VITSITS330 is the CCIR line 330: white bar, 2T pulse and a five-step
+	// staircase with superimposed subcarrier.
+	VITSITS330
+	// VITSITS331 is the CCIR line 331: chrominance bar and subcarrier
+	// reference on a 50% pedestal.
+	VITSITS331
+)
+
+// TestSignalNames maps the names accepted by -vits to test signals.
+var TestSignalNames = map[string]TestSignal{
+	"ntc7-composite": VITSComposite,
+	"multiburst":     VITSMultiburst,
+	"staircase":      VITSStaircase,
+	"pulse-bar":      VITSPulseBar,
+	"its17":          VITSITS17,
+	"its18":          VITSMultiburst,
+	"its330":         VITSITS330,
+	"its331":         VITSITS331,
+}
+
+// TestLinePresets are the usual line assignments, selectable by name.
+var TestLinePresets = map[string]map[int]TestSignal{
+	"ntc7": {17: VITSComposite, 18: VITSMultiburst, 280: VITSComposite, 281: VITSMultiburst},
+	"its":  {17: VITSITS17, 18: VITSMultiburst, 330: VITSITS330, 331: VITSITS331},
+}
+
+// ParseTestSignals parses a -vits spec: either a preset name ("ntc7" or
+// "its") or comma-separated line=signal pairs such as "17=its17,18=multiburst".
+func ParseTestSignals(spec string) (map[int]TestSignal, error) {
+	if preset, ok := TestLinePresets[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		return preset, nil
+	}
+	signals := make(map[int]TestSignal)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		num, name, ok := strings.Cut(part, "=")
+		line, err := strconv.Atoi(strings.TrimSpace(num))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("bad test line %q (want line=signal)", part)
+		}
+		sig, ok := TestSignalNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown test signal %q (want one of %s)", name, testSignalList())
+		}
+		signals[line] = sig
+	}
+	return signals, nil
+}
+
+func testSignalList() string {
+	names := make([]string, 0, len(TestSignalNames))
+	for name := range TestSignalNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// The test line layouts are specified in microseconds from the leading edge
+// of sync on a line whose active video runs from 10.5 µs for 52 µs. They are
+// stretched to fit the active video of the standard in use.
+const (
+	vitsNominalStart  = 10.5
+	vitsNominalLength = 52.0
+	// vitsChromaRise is the rise time of the subcarrier packet envelopes.
+	vitsChromaRise = 0.5e-6
+	// vitsPacketRise is the rise time of the multiburst packet envelopes.
+	vitsPacketRise = 0.4e-6
+)
+
+// Multiburst packet frequencies for 525-line and 625-line systems.
+var (
+	multiburst525 = []float64{0.5e6, 1.0e6, 2.0e6, 3.0e6, 3.58e6, 4.2e6}
+	multiburst625 = []float64{0.5e6, 1.0e6, 2.0e6, 4.0e6, 4.8e6, 5.8e6}
+)
+
+// InsertLine writes the test signal over the active part of a VBI line.
+// Modulated parts are sent in burst phase; standards without a quadrature
+// subcarrier get the luminance parts only.
+func (sig TestSignal) InsertLine(info LineInfo, lineBuffer []float64) {
+	modulated := info.Params.Colour == ColourNTSC || info.Params.Colour == ColourPAL
+	for s := info.ActiveStartSamples; s < info.ActiveStartSamples+info.ActiveSamples; s++ {
+		var sc float64
+		if modulated {
+			sc = math.Sin(info.SubcarrierPhase(s) + math.Pi)
+		}
+		lineBuffer[s] = sig.level(info, info.Time(s), sc)
+	}
+}
+
+// level returns the level in IRE of the test signal at time t from the
+// leading edge of sync, given the burst-phase subcarrier value sc.
+func (sig TestSignal) level(info LineInfo, t, sc float64) float64 {
+	p := info.Params
+	at := func(us float64) float64 {
+		return p.ActiveStart + (us-vitsNominalStart)/vitsNominalLength*p.ActiveLength
+	}
+	white := p.LevelWhite - p.LevelBlack
+
+	// T is the half-amplitude duration unit of the pulses: half a period of
+	// the highest frequency the channel (and the sample rate) can carry.
+	bandwidth := math.Min(p.Bandwidth, 0.45*info.SampleRate)
+	tUnit := 1.0 / (2.0 * bandwidth)
+	rise := 2.0 * tUnit
+
+	// The modulated pulse is 12.5T on 525-line NTSC and 20T elsewhere, in
+	// units of the nominal channel T rather than the sampled one.
+	modHAD := 20.0 / (2.0 * p.Bandwidth)
+	if p.Colour == ColourNTSC {
+		modHAD = 12.5 / (2.0 * p.Bandwidth)
+	}
+
+	bar := func(t0, t1, frac float64) float64 {
+		return frac * white * window(t, t0, t1, rise)
+	}
+	pulse2T := func(t0 float64) float64 {
+		return white * sin2Pulse(t, t0, rise)
+	}
+	modulatedPulse := func(t0 float64) float64 {
+		return white / 2.0 * sin2Pulse(t, t0, modHAD) * (1.0 + sc)
+	}
+	// staircase rises in equal steps from black to top over [t0, t1], with a
+	// subcarrier of peak amplitude chroma on every step.
+	staircase := func(t0, t1 float64, levels int, top, chroma float64) float64 {
+		stepLen := (t1 - t0) / float64(levels)
+		var v float64
+		for i := 1; i < levels; i++ {
+			v += edge(t, t0+float64(i)*stepLen, rise)
+		}
+		v *= top * white / float64(levels-1) * (1.0 - edge(t, t1, rise))
+		return v + chroma*window(t, t0, t1, vitsChromaRise)*sc
+	}
+
+	// Everything sits on a black pedestal across the active line.
+	v := p.LevelBlanking + (p.LevelBlack-p.LevelBlanking)*window(t, at(vitsNominalStart), at(vitsNominalStart+vitsNominalLength), rise)
+
+	switch sig {
+	case VITSComposite:
+		v += bar(at(12), at(30), 1.0) + pulse2T(at(34)) + modulatedPulse(at(37))
+		v += staircase(at(42), at(60), 6, 0.9, p.BurstAmplitude)
+	case VITSMultiburst:
+		v += bar(at(12), at(16), 1.0) + bar(at(17), at(61), 0.5)
+		freqs := multiburst525
+		if p.LinesPerFrame >= 625 {
+			freqs = multiburst625
+		}
+		for k, f := range freqs {
+			// Packets the channel or the sample rate cannot carry are left
+			// as bare pedestal.
+			if f > p.Bandwidth || f >= 0.45*info.SampleRate {
+				continue
+			}
+			start := 18.5 + 7.0*float64(k)
+			t0, t1 := at(start), at(start+5.5)
+			v += 0.3 * white * window(t, t0, t1, vitsPacketRise) * math.Sin(2.0*math.Pi*f*(t-t0))
+		}
+	case VITSStaircase:
+		v += staircase(at(12), at(60), 6, 0.9, p.BurstAmplitude)
+	case VITSPulseBar:
+		v += pulse2T(at(16)) + bar(at(22), at(48), 1.0)
+	case VITSITS17:
+		v += bar(at(12), at(22), 1.0) + pulse2T(at(26)) + modulatedPulse(at(30))
+		v += staircase(at(40), at(60), 6, 1.0, 0)
+	case VITSITS330:
+		v += bar(at(12), at(22), 1.0) + pulse2T(at(26))
+		v += staircase(at(30), at(60), 6, 1.0, p.BurstAmplitude)
+	case VITSITS331:
+		v += bar(at(14), at(60), 0.5)
+		v += 1.5 * p.BurstAmplitude * window(t, at(14), at(38), vitsChromaRise) * sc
+		v += p.BurstAmplitude * window(t, at(42), at(60), vitsChromaRise) * sc
+	}
+	return v
+}
+
+// edge rises from 0 to 1 with a sin² transition of the given width centred
+// on t0.
+func edge(t, t0, width float64) float64 {
+	x := (t - t0) / width
+	switch {
+	case x <= -0.5:
+		return 0
+	case x >= 0.5:
+		return 1
+	}
+	s := math.Sin(math.Pi / 2.0 * (x + 0.5))
+	return s * s
+}
+
+// window is 1 between t0 and t1, with sin² edges of the given width.
+func window(t, t0, t1, width float64) float64 {
+	return edge(t, t0, width) - edge(t, t1, width)
+}
+
+// sin2Pulse is a sin² pulse of unit height centred on t0 with the given
+// half-amplitude duration.
+func sin2Pulse(t, t0, had float64) float64 {
+	x := (t - t0) / had
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	c := math.Cos(math.Pi / 2.0 * x)
+	return c * c
+}
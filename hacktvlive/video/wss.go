@@ -0,0 +1,112 @@
+package video
+
+// Widescreen signalling on 625-line systems (ETS 300 294): 137 elements at
+// 5 MHz starting 11 µs after the leading edge of sync on line 23. A run-in
+// and start code are followed by 14 data bits, each sent as 6 elements of
+// biphase code, at 500 mV.
+const (
+	wssStart       = 11.0e-6
+	wssElementRate = 5.0e6
+	wssLevel       = 100.0 * 500.0 / 700.0
+	wssRunIn       = 0x1F1C71C7 // 29 elements
+	wssStartCode   = 0x1E3C1F   // 24 elements
+)
+
+// WSS aspect ratio group (bits b0-b3, b3 being odd parity).
+const (
+	wssAspect4x3  = 0x8 // 0001: 4:3 full format
+	wssAspect16x9 = 0x7 // 1110: 16:9 full format, anamorphic
+)
+
+// WSSInserter sends the aspect ratio of the picture as widescreen signalling.
+type WSSInserter struct {
+	Widescreen bool
+}
+
+// WSSLine returns the line that carries widescreen signalling: the last
+// blanking line before the picture in field 1 (line 23 on 625-line systems).
+func WSSLine(p Params) int {
+	return p.FirstActiveLine - 1
+}
+
+// InsertLine adds the WSS waveform to the line.
+func (w *WSSInserter) InsertLine(info LineInfo, lineBuffer []float64) {
+	data := wssAspect4x3
+	if w.Widescreen {
+		data = wssAspect16x9
+	}
+
+	elements := make([]bool, 0, 137)
+	elements = appendMSBFirst(elements, wssRunIn, 29)
+	elements = appendMSBFirst(elements, wssStartCode, 24)
+	for i := 0; i < 14; i++ {
+		// Biphase: a one is 111000, a zero is 000111.
+		one := data&(1<<i) != 0
+		for e := 0; e < 6; e++ {
+			elements = append(elements, (e < 3) == one)
+		}
+	}
+
+	elementSamples := info.SampleRate / wssElementRate
+	rolloff := nrzRolloff(wssElementRate, info.Params.Bandwidth, info.SampleRate)
+	writeNRZ(lineBuffer, elements, info.Sample(wssStart), elementSamples, wssLevel, rolloff)
+}
+
+// CGMS-A and aspect ratio signalling on 525-line systems (IEC 61880, EIA-J
+// CPR-1204 ID-1): a reference "10" then 20 bits at fsc/8, starting 11.2 µs
+// after the leading edge of sync, ones at 70 IRE.
+const (
+	cgmsStart = 11.2e-6
+	cgmsLevel = 70.0
+)
+
+// CGMSInserter sends the aspect ratio, with copying permitted, as CGMS-A.
+type CGMSInserter struct {
+	Widescreen bool
+}
+
+// CGMSLines returns the lines that carry CGMS-A: two before the first picture
+// line of each field (lines 20 and 283 on 525-line systems).
+func CGMSLines(p Params) []int {
+	line := p.FirstActiveLine - 2
+	return []int{line, line + (p.LinesPerFrame+1)/2}
+}
+
+// InsertLine adds the CGMS-A waveform to the line.
+func (c *CGMSInserter) InsertLine(info LineInfo, lineBuffer []float64) {
+	// Word 0 bit 1 gives the aspect ratio; word 1 and the CGMS-A and APS
+	// bits of word 2 are all zero, meaning copy freely.
+	var data [14]bool
+	data[0] = c.Widescreen
+
+	bits := append([]bool{true, false}, data[:]...)
+	bits = append(bits, cgmsCRC(data[:])...)
+
+	bitSamples := info.SampleRate / (info.Params.Fsc / 8.0)
+	writeNRZ(lineBuffer, bits, info.Sample(cgmsStart), bitSamples, cgmsLevel, 1.0)
+}
+
+// cgmsCRC returns the 6-bit CRC (x^6 + x + 1, preset to all ones) of the
+// 14 data bits, in transmission order.
+func cgmsCRC(data []bool) []bool {
+	crc := 0x3F
+	for _, bit := range data {
+		feedback := crc>>5&1 == 1
+		if bit {
+			feedback = !feedback
+		}
+		crc = crc << 1 & 0x3F
+		if feedback {
+			crc ^= 0x03
+		}
+	}
+	return appendMSBFirst(nil, crc, 6)
+}
+
+// appendMSBFirst appends the low n bits of v, most significant first.
+func appendMSBFirst(bits []bool, v, n int) []bool {
+	for i := n - 1; i >= 0; i-- {
+		bits = append(bits, v&(1<<i) != 0)
+	}
+	return bits
+}